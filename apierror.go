@@ -1,6 +1,9 @@
 package wns
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
 // ErrType is a type to indicate different WNS feed error types
 type ErrType int
@@ -14,14 +17,36 @@ const (
 	ErrTypeTooFrequent
 	// ErrTypeUnknown is a type for random errors
 	ErrTypeUnknown
+	// ErrTypeAuth is a type for an error when credentials are rejected
+	// with HTTP 401 or 403 status
+	ErrTypeAuth
+	// ErrTypeServer is a type for an error when WNS or a proxy in front of
+	// it responds with 5xx HTTP status
+	ErrTypeServer
+	// ErrTypeContentType is a type for an error when response is not an XML
+	// document, e.g. HTML error page
+	ErrTypeContentType
+	// ErrTypeTruncated is a type for an error when response body is empty
+	// or ends before XML document is complete
+	ErrTypeTruncated
 )
 
 // APIError is an error wrapper to distinguish between known and unknown
 // feed errors, so additional logic could be done on errors that are
 // actually only informational messages concealed in error format
+//
+// APIError values can be matched by type or by error kind with errors.Is, for
+// example:
+//
+//	errors.Is(err, &wns.APIError{Type: wns.ErrTypeAuth})
+//	errors.Is(err, wns.ErrAuth)
 type APIError struct {
-	Type ErrType
-	Err  string
+	Type       ErrType
+	Err        string
+	StatusCode int    // HTTP status code of response, if known
+	Body       string // Excerpt of response body, for errors detected from HTTP response
+
+	cause error
 }
 
 // Error returns an error message from type and error value
@@ -34,6 +59,69 @@ func (e *APIError) Error() string {
 		t = "Too frequent requests"
 	case ErrTypeUnknown:
 		t = "Unknown error"
+	case ErrTypeAuth:
+		t = "Authentication failed"
+	case ErrTypeServer:
+		t = "Server error"
+	case ErrTypeContentType:
+		t = "Unexpected content type"
+	case ErrTypeTruncated:
+		t = "Truncated response"
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s (HTTP %d: %s)", t, e.StatusCode, e.Err)
 	}
 	return fmt.Sprintf("%s (%s)", t, e.Err)
 }
+
+// Is reports whether target is an *APIError of the same type or the kind of
+// this error.
+func (e *APIError) Is(target error) bool {
+	if t, ok := target.(*APIError); ok {
+		return t.Type == e.Type
+	}
+	kind := e.kind()
+	return kind != nil && target == kind
+}
+
+// Temporary reports whether request could succeed if retried later.
+func (e *APIError) Temporary() bool {
+	return temporary(e.kind())
+}
+
+// kind maps error type to one of the error kinds. Unknown errors are left
+// unclassified.
+func (e *APIError) kind() error {
+	switch e.Type {
+	case ErrTypeNoNew:
+		return ErrNoData
+	case ErrTypeTooFrequent:
+		return ErrRateLimited
+	case ErrTypeAuth:
+		return ErrAuth
+	case ErrTypeServer, ErrTypeContentType, ErrTypeTruncated:
+		return ErrTransport
+	}
+	return nil
+}
+
+// Unwrap returns underlying error that caused this one, e.g. XML syntax error
+// for truncated responses.
+func (e *APIError) Unwrap() error {
+	return e.cause
+}
+
+// maxExcerpt is the maximum length of response body excerpt kept in APIError.
+const maxExcerpt = 256
+
+// excerpt returns the beginning of response body, cut at valid UTF-8 boundary.
+func excerpt(body []byte) string {
+	if len(body) <= maxExcerpt {
+		return string(body)
+	}
+	body = body[:maxExcerpt]
+	for len(body) > 0 && !utf8.Valid(body) {
+		body = body[:len(body)-1]
+	}
+	return string(body) + "..."
+}
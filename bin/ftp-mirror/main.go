@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/internal/atomicfile"
+
+	"github.com/sirupsen/logrus"
+)
+
+// manifestEntry describes a single mirrored file.
+type manifestEntry struct {
+	Size     uint64    `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Modified time.Time `json:"modified"`
+	Mirrored time.Time `json:"mirrored"`
+}
+
+// manifest lists all files mirrored so far, keyed by file name.
+type manifest map[string]manifestEntry
+
+func loadManifest(filename string) (manifest, error) {
+	bs, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := manifest{}
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// save atomically replaces manifest file.
+func (m manifest) save(filename string) error {
+	bs, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filename, 0600, func(w io.Writer) error {
+		_, err := w.Write(bs)
+		return err
+	})
+}
+
+type mirror struct {
+	client   *wns.FTPPullClient
+	dir      string
+	manifest string
+	delete   bool
+}
+
+// run mirrors all new remote files and returns the number of mirrored files.
+func (m *mirror) run(ctx context.Context) (int, error) {
+	state, err := loadManifest(m.manifest)
+	if err != nil {
+		return 0, fmt.Errorf("loading manifest: %w", err)
+	}
+
+	files, err := m.client.ListFiles(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing remote files: %w", err)
+	}
+	var missing []string
+	remote := make(map[string]wns.FTPFile, len(files))
+	for _, f := range files {
+		remote[f.Name] = f
+		if _, ok := state[f.Name]; !ok {
+			missing = append(missing, f.Name)
+		}
+	}
+
+	var mirrored []string
+	err = m.client.Download(ctx, missing, func(filename string, r io.Reader) error {
+		if filepath.Base(filename) != filename {
+			return fmt.Errorf("mirroring %s: invalid file name", filename)
+		}
+		f := remote[filename]
+		hash := sha256.New()
+		var size int64
+		err := atomicfile.WriteFile(filepath.Join(m.dir, filename), 0600, func(w io.Writer) error {
+			var err error
+			size, err = io.Copy(io.MultiWriter(w, hash), r)
+			if err != nil {
+				return err
+			}
+			if uint64(size) != f.Size {
+				return fmt.Errorf("size mismatch: got %d bytes, expected %d", size, f.Size)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("mirroring %s: %w", filename, err)
+		}
+		state[filename] = manifestEntry{
+			Size:     uint64(size),
+			SHA256:   hex.EncodeToString(hash.Sum(nil)),
+			Modified: f.Time,
+			Mirrored: time.Now(),
+		}
+		mirrored = append(mirrored, filename)
+		logrus.WithField("file", filename).WithField("size", size).Info("mirrored")
+		return nil
+	})
+	if len(mirrored) > 0 {
+		if err := state.save(m.manifest); err != nil {
+			return 0, fmt.Errorf("saving manifest: %w", err)
+		}
+	}
+	if err != nil {
+		return len(mirrored), err
+	}
+
+	if !m.delete {
+		return len(mirrored), nil
+	}
+	// Files mirrored in earlier passes are removed too, in case their
+	// removal failed before.
+	var remove []string
+	for _, f := range files {
+		if _, ok := state[f.Name]; ok {
+			remove = append(remove, f.Name)
+		}
+	}
+	if len(remove) > 0 {
+		if err := m.client.RemoveContext(ctx, remove); err != nil {
+			return len(mirrored), fmt.Errorf("removing remote files: %w", err)
+		}
+	}
+	return len(mirrored), nil
+}
+
+func main() {
+	var baseURL string
+	var credentialsFile string
+	var dir string
+	var manifestFile string
+	var delete bool
+	var interval time.Duration
+	var minAge time.Duration
+	var sizeCheckDelay time.Duration
+
+	flag.StringVar(&baseURL, "url", "ftp://ftp.betradar.com/wns", "Base URL for FTP-pull delivery method")
+	flag.StringVar(&credentialsFile, "credentials", "", "File with username:password credentials used if URL has none, default is to read WNS_USERNAME and WNS_PASSWORD environment variables")
+	flag.StringVar(&dir, "dir", ".", "Local directory to mirror odds documents to")
+	flag.StringVar(&manifestFile, "manifest", "", "Manifest of mirrored files (default is .manifest.json in mirror directory)")
+	flag.BoolVar(&delete, "delete", false, "Delete remote files after successful mirror")
+	flag.DurationVar(&interval, "interval", 0, "Recheck interval for continuous mirroring, mirror once if zero")
+	flag.DurationVar(&minAge, "min-age", 0, "Minimum age of remote file modification time for file to be mirrored")
+	flag.DurationVar(&sizeCheckDelay, "size-check-delay", 5*time.Second, "Delay between two listings compared for file size changes to detect files still being uploaded, 0 disables the check")
+	flag.Parse()
+
+	if manifestFile == "" {
+		manifestFile = filepath.Join(dir, ".manifest.json")
+	}
+
+	c, err := wns.NewFTPPullWithOptions(baseURL,
+		wns.FTPCredentials(wns.DefaultCredentials(credentialsFile)),
+		wns.FTPStabilityCheck(wns.FTPStability{MinAge: minAge, SizeCheckDelay: sizeCheckDelay}),
+	)
+	if err != nil {
+		logrus.WithError(err).Fatal("creating FTP-pull client")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logrus.WithError(err).Fatal("creating mirror directory")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	m := &mirror{
+		client:   c,
+		dir:      dir,
+		manifest: manifestFile,
+		delete:   delete,
+	}
+	for {
+		n, err := m.run(ctx)
+		if err != nil && ctx.Err() == nil {
+			if interval == 0 {
+				logrus.WithError(err).Fatal("mirroring remote files")
+			}
+			logrus.WithError(err).Error("mirroring remote files")
+		}
+		logrus.WithField("files", n).Info("mirror pass complete")
+		if interval == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
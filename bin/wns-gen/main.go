@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/wnstest"
+	"github.com/advbet/wns/wnstest/generator"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var dir string
+	var addr string
+	var bookmaker string
+	var speed float64
+	var scale int
+	var seed int64
+	var duration time.Duration
+
+	flag.StringVar(&dir, "dir", "", "Directory to write generated odds documents to")
+	flag.StringVar(&addr, "listen", "", "Address to serve generated odds documents on via fake HTTP-pull endpoint")
+	flag.StringVar(&bookmaker, "bookmaker", "bookmaker", "Bookmaker name accepted by fake HTTP-pull endpoint, key is read from WNS_PASSWORD environment variable")
+	flag.Float64Var(&speed, "speed", 1, "Simulation speed factor, 0 generates documents without delays")
+	flag.IntVar(&scale, "scale", 1, "Number of copies of the game catalog to simulate")
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(), "Seed of random draws")
+	flag.DurationVar(&duration, "duration", 0, "Simulated time to generate documents for, 0 runs until interrupted")
+	flag.Parse()
+
+	g := &generator.Generator{
+		Games: generator.Replicate(generator.DefaultCatalog, scale),
+		Speed: speed,
+		Seed:  seed,
+	}
+	if speed == 0 {
+		g.Speed = math.Inf(1)
+	}
+	if duration > 0 {
+		g.Start = time.Now()
+		g.End = g.Start.Add(duration)
+	}
+
+	var sink generator.Sink = generator.SinkFunc(func(ctx context.Context, doc generator.Document) error {
+		fmt.Printf("==== %s %s ====\n", doc.Time.Format(time.RFC3339), doc.Data.Type)
+		fmt.Println(doc.Data)
+		return nil
+	})
+	switch {
+	case dir != "":
+		sink = generator.DirSink{Dir: dir}
+	case addr != "":
+		srv := &wnstest.Server{
+			Bookmaker: bookmaker,
+			Key:       os.Getenv(wns.DefaultPasswordVar),
+			RateLimit: wnstest.DefaultRateLimit,
+		}
+		go func() {
+			logrus.WithError(http.ListenAndServe(addr, srv)).Fatal("serving HTTP-pull requests")
+		}()
+		sink = generator.ServerSink{Server: srv}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := g.Run(ctx, sink); err != nil && err != context.Canceled {
+		logrus.WithError(err).Fatal("generating documents")
+	}
+}
@@ -12,27 +12,40 @@ import (
 )
 
 func main() {
+	var credentialsFile string
 	var name string
 	var key string
 	var url string
 	var delete bool
+	var archive string
 
-	flag.StringVar(&name, "name", "", "Bookmaker name/ID")
-	flag.StringVar(&key, "key", "", "Feed access key")
+	flag.StringVar(&credentialsFile, "credentials", "", "File with bookmaker name and feed key as name:key, default is to read WNS_USERNAME and WNS_PASSWORD environment variables")
+	flag.StringVar(&name, "name", "", "Bookmaker name/ID (deprecated, use -credentials instead)")
+	flag.StringVar(&key, "key", "", "Feed access key (deprecated, key is visible in process list, use -credentials instead)")
 	flag.StringVar(&url, "url", "https://www.betradar.com/betradar/getXmlFeed.php", "Feed HTTP pull base URL")
 	flag.BoolVar(&delete, "delete", false, "Delete feed document after transfer")
+	flag.StringVar(&archive, "archive", "", "Directory to archive raw feed documents to")
 	flag.Parse()
 
+	if name != "" || key != "" {
+		log.Print("-name and -key flags are deprecated, use -credentials or WNS_USERNAME and WNS_PASSWORD environment variables")
+	}
+
 	client := wns.HTTPPullClient{
-		Username: name,
-		Key:      key,
-		URL:      url,
-		Interval: 30 * time.Second,
+		Username:    name,
+		Key:         key,
+		Credentials: wns.DefaultCredentials(credentialsFile),
+		URL:         url,
+		Interval:    30 * time.Second,
 		HTTPClient: http.Client{
 			Timeout: 5 * time.Second,
 		},
 	}
 
+	if archive != "" {
+		client.Recorder = &wns.FSRecorder{Dir: archive, Compress: true}
+	}
+
 	data, err := client.Get(context.TODO(), delete)
 	if err != nil {
 		log.Fatal(err)
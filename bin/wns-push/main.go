@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/advbet/wns"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var addr string
+	var allow string
+	var archive string
+	var ack string
+
+	flag.StringVar(&addr, "listen", ":8080", "Address to listen for HTTP-push requests on")
+	flag.StringVar(&allow, "allow", "", "Comma separated list of IP addresses or networks allowed to push documents")
+	flag.StringVar(&archive, "archive", "", "Directory to archive raw odds documents to")
+	flag.StringVar(&ack, "ack", "", "Response body acknowledging received documents, as agreed with Betradar (required)")
+	flag.Parse()
+
+	if ack == "" {
+		logrus.Fatal("-ack flag is required")
+	}
+
+	// Shared secret sender has to present as key parameter or basic auth
+	// password is read from WNS_PUSH_SECRET environment variable, so it is
+	// not visible in process list.
+	h := &wns.HTTPPushHandler{Secret: os.Getenv("WNS_PUSH_SECRET"), AckBody: ack}
+	if allow != "" {
+		h.AllowedIPs = strings.Split(allow, ",")
+	}
+	if archive != "" {
+		h.Recorder = &wns.FSRecorder{Dir: archive, Compress: true}
+	}
+
+	go func() {
+		logrus.WithError(http.ListenAndServe(addr, h)).Fatal("serving HTTP-push requests")
+	}()
+
+	for msg := range h.Stream(context.TODO()) {
+		if msg.Error != nil {
+			logrus.WithError(msg.Error).Error("stream error")
+			continue
+		}
+		fmt.Printf("==== %s ====\n", msg.Source)
+		fmt.Println(msg.Data)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/advbet/wns"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var sourceURL string
+	var cursor string
+	var credentialsFile string
+
+	flag.StringVar(&sourceURL, "source", "ftp://ftp.betradar.com/wns", "Source URL, see wns.OpenSource for supported URLs")
+	flag.StringVar(&credentialsFile, "credentials", "", "File with username:password credentials used if URL has none, default is to read WNS_USERNAME and WNS_PASSWORD environment variables")
+	flag.StringVar(&cursor, "cursor", "", "Cursor of the last processed odds document")
+	flag.Parse()
+
+	src, err := wns.OpenSourceWithCredentials(sourceURL, wns.DefaultCredentials(credentialsFile))
+	if err != nil {
+		logrus.WithError(err).Fatal("opening source")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for msg := range src.Stream(ctx, cursor) {
+		if msg.Status == wns.StatusStopped {
+			logrus.WithError(msg.Error).Fatal("stream stopped")
+		}
+		if msg.Error != nil {
+			logrus.WithError(msg.Error).Error("stream error")
+			continue
+		}
+		if msg.Status != wns.StatusDocument {
+			continue
+		}
+		fmt.Printf("==== %s ====\n", msg.Cursor)
+		fmt.Println(msg.Data)
+		if err := src.Ack(msg); err != nil {
+			logrus.WithError(err).Error("acknowledging document")
+		}
+	}
+}
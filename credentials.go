@@ -0,0 +1,155 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Credentials are WNS account credentials. For HTTP-pull delivery method
+// Username is the bookmaker name and Password is the feed key.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsProvider supplies credentials to WNS clients. Clients ask for
+// credentials before each request, so they can be rotated without restarting
+// the process.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsFunc is an adapter to use ordinary functions as a
+// CredentialsProvider, e.g. callbacks fetching rotated credentials from a
+// secret store.
+type CredentialsFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials implements CredentialsProvider interface.
+func (f CredentialsFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// ErrNoCredentials is reported by credentials providers when credentials are
+// missing or malformed, so clients fail with ErrAuth instead of retrying.
+// Providers should wrap it, e.g. fmt.Errorf("...: %w", ErrNoCredentials).
+// Other provider errors, e.g. unavailable secret store, are considered
+// temporary.
+var ErrNoCredentials = errors.New("wns: no credentials")
+
+// Default environment variables read by EnvCredentials.
+const (
+	DefaultUsernameVar = "WNS_USERNAME"
+	DefaultPasswordVar = "WNS_PASSWORD"
+)
+
+// EnvCredentials reads credentials from environment variables.
+type EnvCredentials struct {
+	UsernameVar string // Default is DefaultUsernameVar
+	PasswordVar string // Default is DefaultPasswordVar
+}
+
+// Credentials implements CredentialsProvider interface. Password variable
+// must be set.
+func (e EnvCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	usernameVar := e.UsernameVar
+	if usernameVar == "" {
+		usernameVar = DefaultUsernameVar
+	}
+	passwordVar := e.PasswordVar
+	if passwordVar == "" {
+		passwordVar = DefaultPasswordVar
+	}
+	password, ok := os.LookupEnv(passwordVar)
+	if !ok {
+		return Credentials{}, fmt.Errorf("environment variable %s is not set: %w", passwordVar, ErrNoCredentials)
+	}
+	return Credentials{Username: os.Getenv(usernameVar), Password: password}, nil
+}
+
+// DefaultCredentials returns provider reading credentials from `filename` or,
+// if it is empty, from DefaultUsernameVar and DefaultPasswordVar environment
+// variables. It is meant for command line tools taking optional credentials
+// file.
+func DefaultCredentials(filename string) CredentialsProvider {
+	if filename != "" {
+		return FileCredentials{Path: filename}
+	}
+	return EnvCredentials{}
+}
+
+// FileCredentials reads credentials from a file, e.g. a mounted secret. File
+// is read on every call, replacing the file rotates credentials.
+type FileCredentials struct {
+	Path string
+	// Username, if set, is the user name and the whole file is the
+	// password. Otherwise file holds "username:password". Trailing new
+	// line is ignored.
+	Username string
+}
+
+// Credentials implements CredentialsProvider interface.
+func (f FileCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	bs, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	content := strings.TrimRight(string(bs), "\r\n")
+	if f.Username != "" {
+		return Credentials{Username: f.Username, Password: content}, nil
+	}
+	i := strings.IndexByte(content, ':')
+	if i == -1 {
+		return Credentials{}, fmt.Errorf("credentials file %s is not in username:password format: %w", f.Path, ErrNoCredentials)
+	}
+	return Credentials{Username: content[:i], Password: content[i+1:]}, nil
+}
+
+// getCredentials asks provider for credentials, wrapping its failure. Missing
+// or rejected credentials are reported as ErrAuth, other failures are left
+// unclassified.
+func getCredentials(ctx context.Context, p CredentialsProvider) (Credentials, error) {
+	cred, err := p.Credentials(ctx)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Credentials{}, ctxErr
+		}
+		var kind error
+		if errors.Is(err, ErrNoCredentials) || errors.Is(err, ErrAuth) {
+			kind = ErrAuth
+		}
+		return Credentials{}, &Error{Kind: kind, Op: "credentials", Err: err}
+	}
+	return cred, nil
+}
+
+// redacted replaces credentials removed by Redact.
+const redacted = "REDACTED"
+
+var (
+	redactUserinfo = regexp.MustCompile(`(://[^/?#@\s:]*:)[^/?#\s]*@`)
+	redactKey      = regexp.MustCompile(`([?&;]key=)[^&#\s"]*`)
+)
+
+// Redact returns `s` with credentials in URLs replaced by "REDACTED". It
+// redacts passwords in URL user info and values of `key` query parameter, so
+// URLs and error messages can be logged safely.
+func Redact(s string) string {
+	s = redactUserinfo.ReplaceAllString(s, "${1}"+redacted+"@")
+	return redactKey.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// redactErr redacts credentials from URL of url.Error, e.g. returned by
+// url.Parse or http.Client.
+func redactErr(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = Redact(urlErr.URL)
+	}
+	return err
+}
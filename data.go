@@ -0,0 +1,129 @@
+package wns
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+)
+
+// Status describes what kind of event a streamed Data value reports.
+type Status int
+
+const (
+	// StatusDocument is reported for received documents and errors.
+	StatusDocument Status = iota
+	// StatusThrottled is reported when stream slows down to respect WNS
+	// rate limit. Data.Wait holds the delay before the next request.
+	StatusThrottled
+	// StatusDrained is reported when HTTP-pull stream in drain mode emptied
+	// the queue. Data.Drained holds the number of fetched documents.
+	StatusDrained
+	// StatusStopped is reported as the last value of a stream that stopped
+	// because credentials were rejected. Data.Error holds the error.
+	StatusStopped
+)
+
+// Data wraps BetradarBetData and error to be returned via streamed channel.
+// Together with the parsed document it carries the raw payload exactly as it
+// was received from Betradar and its metadata.
+//
+// Streams can also deliver informational events that carry no document, they
+// are distinguished by Status field.
+type Data struct {
+	Data     BetradarBetData
+	Filename string
+	Error    error
+
+	Status  Status
+	Wait    time.Duration // Delay before next request, for StatusThrottled
+	Drained int           // Number of documents fetched while draining the queue
+
+	Raw      []byte    // Raw document payload
+	Size     int       // Size of raw payload in bytes
+	SHA256   string    // Hex encoded SHA-256 hash of raw payload
+	Received time.Time // Time when document was received
+	Source   string    // FTP file URL, HTTP URL, local file URL or HTTP-push sender address document was received from
+	Charset  string    // Character encoding declared by document
+	Cursor   string    // Position of the document in its source, see Source
+
+	ack func() error
+}
+
+// Ack acknowledges that document was processed by the consumer. For documents
+// streamed via a spool, Ack removes document from the spool, so it will not
+// be redelivered after restart. For other documents Ack is a no-op.
+func (d Data) Ack() error {
+	if d.ack == nil {
+		return nil
+	}
+	return d.ack()
+}
+
+// newData creates Data for raw document received from `source`. Document
+// is not parsed, see decode method.
+func newData(raw []byte, source, filename string) Data {
+	hash := sha256.Sum256(raw)
+	return Data{
+		Filename: filename,
+		Raw:      raw,
+		Size:     len(raw),
+		SHA256:   hex.EncodeToString(hash[:]),
+		Received: time.Now(),
+		Source:   source,
+		Charset:  declaredCharset(raw),
+	}
+}
+
+// declaredCharset returns charset declared by XML declaration of `raw`
+// document, so it is known before the document is decoded. Documents without
+// declaration are UTF-8.
+func declaredCharset(raw []byte) string {
+	charset := "UTF-8"
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		charset = label
+		return input, nil
+	}
+	_, _ = dec.RawToken()
+	return charset
+}
+
+// decode parses raw document into Data field, detecting its charset.
+func (d *Data) decode() error {
+	dec := xml.NewDecoder(bytes.NewReader(d.Raw))
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		d.Charset = charset
+		return charsetReader(charset, input)
+	}
+	return dec.Decode(&d.Data)
+}
+
+// sendData delivers `d` to the stream consumer. It returns false if `ctx` was
+// cancelled before consumer received the value.
+func sendData(ctx context.Context, ch chan<- Data, d Data) bool {
+	select {
+	case ch <- d:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// streamErr delivers error of a failed stream poll. Authentication failures
+// are delivered with StatusStopped, as polling can not succeed until
+// credentials are fixed. It returns false if stream should stop.
+func streamErr(ctx context.Context, ch chan<- Data, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrAuth) {
+		sendData(ctx, ch, Data{Error: err, Status: StatusStopped})
+		return false
+	}
+	return sendData(ctx, ch, Data{Error: err})
+}
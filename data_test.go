@@ -0,0 +1,54 @@
+package wns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataDecode(t *testing.T) {
+	tests := []struct {
+		msg     string
+		raw     string
+		charset string
+		country string
+	}{
+		{
+			msg:     "utf-8 document",
+			raw:     `<?xml version="1.0" encoding="UTF-8"?><BetradarBetData><Sports><Sport><Category IsoName="Curaçao"/></Sport></Sports></BetradarBetData>`,
+			charset: "UTF-8",
+			country: "Curaçao",
+		},
+		{
+			msg:     "document without declaration",
+			raw:     `<BetradarBetData><Sports><Sport><Category IsoName="Malta"/></Sport></Sports></BetradarBetData>`,
+			charset: "UTF-8",
+			country: "Malta",
+		},
+		{
+			msg:     "latin1 document",
+			raw:     "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><BetradarBetData><Sports><Sport><Category IsoName=\"Cura\xe7ao\"/></Sport></Sports></BetradarBetData>",
+			charset: "ISO-8859-1",
+			country: "Curaçao",
+		},
+	}
+
+	for _, test := range tests {
+		before := time.Now()
+		d := newData([]byte(test.raw), "ftp://ftp.betradar.com:21/wns/a.xml", "a.xml")
+		assert.Equal(t, test.charset, d.Charset, "%s: declared charset", test.msg)
+		require.NoError(t, d.decode(), test.msg)
+		assert.Equal(t, test.charset, d.Charset, test.msg)
+		assert.Equal(t, test.country, d.Data.Sports[0].Category.Country, test.msg)
+		assert.Equal(t, []byte(test.raw), d.Raw, test.msg)
+		assert.Equal(t, len(test.raw), d.Size, test.msg)
+		assert.Len(t, d.SHA256, 64, test.msg)
+		assert.False(t, d.Received.Before(before), test.msg)
+		assert.Equal(t, "a.xml", d.Filename, test.msg)
+	}
+
+	d := newData([]byte("abc"), "", "")
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", d.SHA256)
+}
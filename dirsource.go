@@ -0,0 +1,205 @@
+package wns
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DirSource is a Source reading odds documents from a local directory, e.g.
+// files dropped by hand or copied from another environment. Documents are
+// delivered in chronological order, cursor is the document file name.
+//
+// Zero value DirSource with Dir set delivers all documents found in the
+// directory and closes the stream.
+type DirSource struct {
+	Dir string // Directory to read documents from
+	// Pattern selects document files by name, see path.Match for syntax.
+	// Default is "*.xml".
+	Pattern string
+	// Order is a strategy for sorting documents in chronological order.
+	// File modification times are used as FTPFile.Time. Default is
+	// OrderByModTime.
+	Order FTPOrder
+	// Interval, if non zero, enables watching the directory for new
+	// documents by polling it every Interval. Otherwise stream is closed
+	// once existing documents are delivered.
+	Interval time.Duration
+	// MinAge, if non zero, delays documents until they are at least
+	// MinAge old, so that files still being copied are not read. Newer
+	// documents block older ones to keep chronological order.
+	MinAge time.Duration
+	// DoneDir, if set, is a directory acknowledged documents are moved to.
+	DoneDir string
+}
+
+// Stream implements Source interface. Documents failing to parse are
+// delivered with Error set, acknowledging them moves them to DoneDir as well.
+//
+// Stream remembers names of delivered documents, so documents appearing later
+// are delivered even if they sort before already delivered ones, e.g. files
+// copied with preserved modification times. If DoneDir is set, cursor is
+// ignored and all documents left in the directory are delivered, as they are
+// not acknowledged yet. Otherwise documents up to and including `cursor` in
+// chronological order are skipped.
+func (s *DirSource) Stream(ctx context.Context, cursor string) <-chan Data {
+	ch := make(chan Data)
+	if s.DoneDir != "" {
+		cursor = ""
+	}
+	go func() {
+		defer close(ch)
+		delivered := make(map[string]bool)
+		var tick <-chan time.Time
+		if s.Interval > 0 {
+			ticker := time.NewTicker(s.Interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			var ok bool
+			if cursor, ok = s.streamPoll(ctx, ch, delivered, cursor); !ok || tick == nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+	}()
+	return ch
+}
+
+// Ack implements Source interface.
+func (s *DirSource) Ack(d Data) error {
+	return d.Ack()
+}
+
+// streamPoll delivers documents not found in `delivered` set and adds them to
+// it. Documents up to and including `cursor` are added to the set without
+// delivering them, the returned cursor is empty once it was applied. It
+// returns false if stream should stop.
+func (s *DirSource) streamPoll(ctx context.Context, ch chan<- Data, delivered map[string]bool, cursor string) (string, bool) {
+	files, ready, err := s.list(time.Now())
+	if err != nil {
+		return cursor, streamErr(ctx, ch, &Error{Kind: ErrTransport, Op: "list", Err: err})
+	}
+	if cursor != "" {
+		for i := range files {
+			if files[i].Name != cursor {
+				continue
+			}
+			for _, f := range files[:i+1] {
+				delivered[f.Name] = true
+			}
+		}
+		cursor = ""
+	}
+	// Names of removed files are forgotten, so the set does not grow
+	// with documents moved to DoneDir.
+	present := make(map[string]bool, len(files))
+	for _, f := range files {
+		present[f.Name] = true
+	}
+	for name := range delivered {
+		if !present[name] {
+			delete(delivered, name)
+		}
+	}
+
+	for _, f := range files[:ready] {
+		if delivered[f.Name] {
+			continue
+		}
+		d, err := s.read(f.Name)
+		if err != nil {
+			return cursor, streamErr(ctx, ch, err)
+		}
+		if !sendData(ctx, ch, d) {
+			return cursor, false
+		}
+		delivered[f.Name] = true
+	}
+	return cursor, true
+}
+
+// list returns document files in chronological order. Only the first `ready`
+// files are old enough to be read, the list is cut at the first file younger
+// than MinAge.
+func (s *DirSource) list(now time.Time) (files []FTPFile, ready int, err error) {
+	infos, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	pattern := s.Pattern
+	if pattern == "" {
+		pattern = "*.xml"
+	}
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		match, err := filepath.Match(pattern, info.Name())
+		if err != nil {
+			return nil, 0, err
+		}
+		if match {
+			files = append(files, FTPFile{Name: info.Name(), Size: uint64(info.Size()), Time: info.ModTime()})
+		}
+	}
+	order := s.order()
+	sort.SliceStable(files, func(i, j int) bool {
+		return order(files[i], files[j])
+	})
+	for i, f := range files {
+		if s.MinAge > 0 && now.Sub(f.Time) < s.MinAge {
+			return files, i, nil
+		}
+	}
+	return files, len(files), nil
+}
+
+func (s *DirSource) order() FTPOrder {
+	if s.Order == nil {
+		return OrderByModTime
+	}
+	return s.Order
+}
+
+// read loads and parses a single document. Parse errors are reported in the
+// returned Data, not as an error.
+func (s *DirSource) read(filename string) (Data, error) {
+	path := filepath.Join(s.Dir, filename)
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Data{}, &Error{Kind: ErrTransport, Op: "read", Filename: filename, Err: err}
+	}
+	source := path
+	if abs, err := filepath.Abs(path); err == nil {
+		source = abs
+	}
+	d := newData(bs, "file://"+filepath.ToSlash(source), filename)
+	d.Cursor = filename
+	if err := d.decode(); err != nil {
+		d.Error = &Error{Kind: ErrDecode, Op: "read", Filename: filename, Err: err}
+	}
+	d.ack = s.mover(filename)
+	return d, nil
+}
+
+// mover returns a function moving acknowledged document to DoneDir.
+func (s *DirSource) mover(filename string) func() error {
+	return func() error {
+		if s.DoneDir == "" {
+			return nil
+		}
+		if err := os.MkdirAll(s.DoneDir, 0755); err != nil {
+			return err
+		}
+		return os.Rename(filepath.Join(s.Dir, filename), filepath.Join(s.DoneDir, filename))
+	}
+}
@@ -0,0 +1,129 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestDoc(t *testing.T, dir, name, data string, mtime time.Time) {
+	filename := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(filename, []byte(data), 0644))
+	require.NoError(t, os.Chtimes(filename, mtime, mtime))
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	done := filepath.Join(t.TempDir(), "done")
+	base := time.Now().Add(-time.Hour)
+	// Names are deliberately not in chronological order.
+	writeTestDoc(t, dir, "c.xml", testDoc("first"), base)
+	writeTestDoc(t, dir, "a.xml", testDoc("second"), base.Add(time.Minute))
+	writeTestDoc(t, dir, "b.xml", "<BetradarBetData>", base.Add(2*time.Minute))
+	writeTestDoc(t, dir, "notes.txt", "not a document", base)
+
+	src, err := OpenSource("file://" + filepath.ToSlash(dir) + "?done=" + filepath.ToSlash(done))
+	require.NoError(t, err)
+
+	var msgs []Data
+	for msg := range src.Stream(context.Background(), "") {
+		msgs = append(msgs, msg)
+	}
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "c.xml", msgs[0].Cursor)
+	assert.Equal(t, "first", msgs[0].Data.Timestamp.Created)
+	assert.Equal(t, "a.xml", msgs[1].Cursor)
+	assert.Equal(t, "b.xml", msgs[2].Cursor)
+	assert.True(t, errors.Is(msgs[2].Error, ErrDecode), msgs[2].Error)
+
+	// Resuming after a document that was already moved to done folder.
+	require.NoError(t, src.Ack(msgs[0]))
+	assert.FileExists(t, filepath.Join(done, "c.xml"))
+	msgs = nil
+	for msg := range src.Stream(context.Background(), "c.xml") {
+		msgs = append(msgs, msg)
+	}
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "a.xml", msgs[0].Cursor)
+}
+
+func TestDirSourceWatch(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeTestDoc(t, dir, "a.xml", testDoc("a"), now.Add(-time.Hour))
+	writeTestDoc(t, dir, "b.xml", testDoc("b"), now)
+
+	src := &DirSource{Dir: dir, Interval: time.Millisecond, MinAge: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := src.Stream(ctx, "")
+
+	msg := <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "a.xml", msg.Cursor)
+
+	// Dropped c.xml is older than b.xml, which is delivered once it
+	// becomes old enough.
+	writeTestDoc(t, dir, "c.xml", testDoc("c"), now.Add(-30*time.Minute))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "b.xml"), now.Add(-20*time.Minute), now.Add(-20*time.Minute)))
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "c.xml", msg.Cursor)
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "b.xml", msg.Cursor)
+
+	cancel()
+	for range stream {
+	}
+}
+
+func TestDirSourceLateFiles(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTestDoc(t, dir, "b.xml", testDoc("b"), mtime)
+
+	src := &DirSource{Dir: dir, Interval: time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := src.Stream(ctx, "")
+	msg := <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "b.xml", msg.Cursor)
+
+	// Copied files keep their modification times, both sort before
+	// already delivered b.xml.
+	staging := t.TempDir()
+	writeTestDoc(t, staging, "a.xml", testDoc("a"), mtime)
+	writeTestDoc(t, staging, "c.xml", testDoc("c"), mtime.Add(-time.Minute))
+	for _, name := range []string{"c.xml", "a.xml"} {
+		require.NoError(t, os.Rename(filepath.Join(staging, name), filepath.Join(dir, name)))
+	}
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "c.xml", msg.Cursor)
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "a.xml", msg.Cursor)
+}
+
+func TestDirSourceCursor(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTestDoc(t, dir, "a.xml", testDoc("a"), mtime)
+	writeTestDoc(t, dir, "b.xml", testDoc("b"), mtime)
+	writeTestDoc(t, dir, "c.xml", testDoc("c"), mtime.Add(time.Minute))
+
+	src := &DirSource{Dir: dir}
+	var cursors []string
+	for msg := range src.Stream(context.Background(), "b.xml") {
+		cursors = append(cursors, msg.Cursor)
+	}
+	assert.Equal(t, []string{"c.xml"}, cursors)
+}
@@ -0,0 +1,93 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// Error kinds reported by FTP-pull and HTTP-pull clients. Errors returned by
+// the clients can be matched against them with errors.Is, for example:
+//
+//	if errors.Is(err, wns.ErrAuth) {
+//		// fix credentials
+//	}
+var (
+	// ErrTransport is a kind of network, protocol or server side failures.
+	ErrTransport = errors.New("wns: transport failure")
+	// ErrAuth is a kind of errors caused by rejected credentials.
+	ErrAuth = errors.New("wns: authentication failed")
+	// ErrDecode is a kind of errors caused by malformed odds documents.
+	ErrDecode = errors.New("wns: malformed document")
+	// ErrRateLimited is a kind of errors caused by too frequent requests.
+	ErrRateLimited = errors.New("wns: rate limited")
+	// ErrNoData is a kind of errors reported when there are no new
+	// documents available.
+	ErrNoData = errors.New("wns: no new documents")
+)
+
+// Error is a failed client operation. It wraps the underlying error together
+// with error kind and the file name of affected odds document.
+type Error struct {
+	Kind     error  // One of ErrTransport, ErrAuth, ErrDecode, ErrRateLimited, ErrNoData or nil if unclassified
+	Op       string // Failed operation, e.g. "list" or "get"
+	Filename string // Odds document file name, if known
+	Err      error  // Underlying error
+}
+
+// Error returns error message with operation and file name.
+func (e *Error) Error() string {
+	msg := "wns: " + e.Op
+	if e.Filename != "" {
+		msg += " " + e.Filename
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of this error.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// Temporary reports whether failed operation could succeed if retried later.
+// Unclassified errors are considered temporary.
+func (e *Error) Temporary() bool {
+	return temporary(e.Kind)
+}
+
+// temporary reports whether errors of `kind` are expected to go away on retry.
+func temporary(kind error) bool {
+	return kind != ErrAuth && kind != ErrDecode
+}
+
+// Retryable reports whether operation that failed with `err` is worth
+// retrying. Context errors returned by cancelled operations and errors of
+// ErrAuth or ErrDecode kind are not retryable, other errors are classified by
+// their Temporary method if they have one and are considered retryable
+// otherwise.
+func Retryable(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) {
+		return t.Temporary()
+	}
+	return true
+}
+
+// ftpErrKind classifies errors returned by ftp package.
+func ftpErrKind(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == ftp.StatusNotLoggedIn {
+		return ErrAuth
+	}
+	return ErrTransport
+}
@@ -0,0 +1,132 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/advbet/wns/wnstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		msg       string
+		err       error
+		kind      error
+		retryable bool
+	}{
+		{
+			msg:       "transport",
+			err:       &Error{Kind: ErrTransport, Op: "get", Err: errors.New("connection reset")},
+			kind:      ErrTransport,
+			retryable: true,
+		},
+		{
+			msg:       "wrapped auth",
+			err:       fmt.Errorf("polling: %w", &Error{Kind: ErrAuth, Op: "login", Err: errors.New("530 Login incorrect")}),
+			kind:      ErrAuth,
+			retryable: false,
+		},
+		{
+			msg:       "decode",
+			err:       &Error{Kind: ErrDecode, Op: "get", Filename: "a.xml", Err: errors.New("EOF")},
+			kind:      ErrDecode,
+			retryable: false,
+		},
+		{
+			msg:       "unclassified",
+			err:       &Error{Op: "get", Err: errors.New("disk full")},
+			retryable: true,
+		},
+		{
+			msg:       "too frequent",
+			err:       &APIError{Type: ErrTypeTooFrequent},
+			kind:      ErrRateLimited,
+			retryable: true,
+		},
+		{
+			msg:       "no new files",
+			err:       &APIError{Type: ErrTypeNoNew},
+			kind:      ErrNoData,
+			retryable: true,
+		},
+		{
+			msg:       "server error",
+			err:       &APIError{Type: ErrTypeServer, StatusCode: 503},
+			kind:      ErrTransport,
+			retryable: true,
+		},
+		{
+			msg:       "unknown API error",
+			err:       &APIError{Type: ErrTypeUnknown},
+			retryable: true,
+		},
+		{
+			msg:       "cancelled",
+			err:       context.Canceled,
+			retryable: false,
+		},
+		{
+			msg:       "plain",
+			err:       errors.New("plain"),
+			retryable: true,
+		},
+		{
+			msg:       "nil",
+			err:       nil,
+			retryable: false,
+		},
+	}
+
+	kinds := []error{ErrTransport, ErrAuth, ErrDecode, ErrRateLimited, ErrNoData}
+	for _, test := range tests {
+		assert.Equal(t, test.retryable, Retryable(test.err), test.msg)
+		for _, kind := range kinds {
+			assert.Equal(t, kind == test.kind, errors.Is(test.err, kind), "%s: %s", test.msg, kind)
+		}
+	}
+}
+
+func TestFTPPullErrors(t *testing.T) {
+	srv := wnstest.NewFTPServer(t)
+	srv.AddFile("good.xml", []byte(testDoc("good")), time.Now())
+	srv.AddFile("partial.xml", []byte("<BetradarBetData>"), time.Now())
+	srv.AddFile("bad.xml", []byte(`<BetradarBetData><Sports><Sport BetradarSportID="x"/></Sports></BetradarBetData>`), time.Now())
+
+	c, err := NewFTPPull(srv.URL())
+	require.NoError(t, err)
+
+	docs, err := c.GetData(context.Background(), []string{"good.xml", "partial.xml"})
+	assert.True(t, errors.Is(err, ErrTransport), err)
+	var wnsErr *Error
+	require.True(t, errors.As(err, &wnsErr))
+	assert.Equal(t, "partial.xml", wnsErr.Filename)
+	assert.True(t, Retryable(err))
+	require.Len(t, docs, 1)
+	assert.Equal(t, "good.xml", docs[0].Filename)
+
+	_, err = c.Get([]string{"good.xml", "bad.xml"})
+	assert.True(t, errors.Is(err, ErrDecode), err)
+	require.True(t, errors.As(err, &wnsErr))
+	assert.Equal(t, "bad.xml", wnsErr.Filename)
+	assert.False(t, Retryable(err))
+
+	_, err = c.Get([]string{"missing.xml"})
+	assert.True(t, errors.Is(err, ErrTransport), err)
+	assert.Contains(t, err.Error(), "missing.xml")
+	assert.True(t, Retryable(err))
+
+	u, err := url.Parse(srv.URL())
+	require.NoError(t, err)
+	u.User = url.UserPassword("user", "wrong")
+	c, err = NewFTPPull(u.String())
+	require.NoError(t, err)
+	_, err = c.List()
+	assert.True(t, errors.Is(err, ErrAuth), err)
+}
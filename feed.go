@@ -1,6 +1,9 @@
 package wns
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"time"
+)
 
 // BetradarBetData is a main structure for parsed document
 type BetradarBetData struct {
@@ -16,6 +19,22 @@ type Timestamp struct {
 	TZ      string `xml:"TimeZone,attr"`
 }
 
+// TimestampLayout is the layout of Timestamp.Created, see time.Parse.
+const TimestampLayout = "Mon 2006-01-02 15:04:05"
+
+// Time parses document creation time in the time zone given by TZ, UTC is
+// used if time zone is not set.
+func (t Timestamp) Time() (time.Time, error) {
+	loc := time.UTC
+	if t.TZ != "" {
+		var err error
+		if loc, err = time.LoadLocation(t.TZ); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.ParseInLocation(TimestampLayout, t.Created, loc)
+}
+
 // Sport is betradar sport struct(ID for lotteries will (should?) always be 108)
 type Sport struct {
 	ID       int      `xml:"BetradarSportID,attr"`
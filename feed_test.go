@@ -3,6 +3,7 @@ package wns
 import (
 	"encoding/xml"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -447,3 +448,38 @@ func TestParseFeed(t *testing.T) {
 		assert.Equal(t, test.expected, actual)
 	}
 }
+
+func TestTimestampTime(t *testing.T) {
+	tests := []struct {
+		ts       Timestamp
+		expected time.Time
+		err      bool
+	}{
+		{
+			ts:       Timestamp{Created: "Mon 2017-03-27 09:19:02", TZ: "UTC"},
+			expected: time.Date(2017, 3, 27, 9, 19, 2, 0, time.UTC),
+		},
+		{
+			ts:       Timestamp{Created: "Mon 2017-03-27 09:19:02"},
+			expected: time.Date(2017, 3, 27, 9, 19, 2, 0, time.UTC),
+		},
+		{
+			ts:       Timestamp{Created: "Mon 2017-03-27 11:19:02", TZ: "Europe/Berlin"},
+			expected: time.Date(2017, 3, 27, 9, 19, 2, 0, time.UTC),
+		},
+		{
+			ts:  Timestamp{Created: "yesterday"},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := test.ts.Time()
+		if test.err {
+			assert.Error(t, err, test.ts.Created)
+			continue
+		}
+		require.NoError(t, err, test.ts.Created)
+		assert.True(t, test.expected.Equal(actual), "%s: %s", test.ts.Created, actual)
+	}
+}
@@ -0,0 +1,104 @@
+package wns
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// FTPOption configures FTPPullClient created with NewFTPPullWithOptions.
+type FTPOption func(*FTPPullClient)
+
+// DialContextFunc is a function used to open network connections, it has the
+// same signature as net.Dialer.DialContext method.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// PassiveMode selects FTP command used to open passive data connections.
+type PassiveMode int
+
+const (
+	// PassiveEPSV uses EPSV command and falls back to PASV if server
+	// does not support it.
+	PassiveEPSV PassiveMode = iota
+	// PassivePASV always uses PASV command.
+	PassivePASV
+)
+
+// FTPDialTimeout sets maximum amount of time to wait for a connection to FTP
+// server to be established. Default is 10 seconds.
+func FTPDialTimeout(timeout time.Duration) FTPOption {
+	return func(c *FTPPullClient) {
+		c.dialTimeout = timeout
+	}
+}
+
+// FTPRetrTimeout sets maximum amount of time a single data connection (file
+// listing or download) can be alive. Default is 1 minute.
+func FTPRetrTimeout(timeout time.Duration) FTPOption {
+	return func(c *FTPPullClient) {
+		c.retrTimeout = timeout
+	}
+}
+
+// FTPCommandTimeout sets maximum amount of time to wait while sending a single
+// command or reading a single reply on FTP control connection. Default is 30
+// seconds.
+func FTPCommandTimeout(timeout time.Duration) FTPOption {
+	return func(c *FTPPullClient) {
+		c.cmdTimeout = timeout
+	}
+}
+
+// FTPPassive selects command used to open passive data connections. Default is
+// PassiveEPSV.
+func FTPPassive(mode PassiveMode) FTPOption {
+	return func(c *FTPPullClient) {
+		c.disableEPSV = mode == PassivePASV
+	}
+}
+
+// FTPDialer sets a custom dialer for both control and data connections. If
+// dialer has no timeout set, FTPDialTimeout value is used.
+func FTPDialer(dialer net.Dialer) FTPOption {
+	return func(c *FTPPullClient) {
+		c.dialer = dialer
+	}
+}
+
+// FTPSourceAddr sets local address used for outgoing connections.
+func FTPSourceAddr(ip net.IP) FTPOption {
+	return func(c *FTPPullClient) {
+		c.dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+}
+
+// FTPDialFunc sets a function used to open both control and data connections,
+// instead of dialing them directly. It can be used to connect through a proxy
+// or to an in-process server in tests. FTPDialer, FTPSourceAddr and
+// FTPDialTimeout options are ignored if dial function is set.
+//
+// Returned connections do not need to be TCP connections. If control connection
+// is not a TCP one, data connections for EPSV replies are dialed using the same
+// host as control connection.
+func FTPDialFunc(dial DialContextFunc) FTPOption {
+	return func(c *FTPPullClient) {
+		c.dialContext = dial
+	}
+}
+
+// FTPRetry sets a policy for retrying failed List, Get and Remove operations,
+// Stream uses it for every poll. By default operations are not retried.
+func FTPRetry(p RetryPolicy) FTPOption {
+	return func(c *FTPPullClient) {
+		c.retry = p
+	}
+}
+
+// FTPCredentials sets provider of FTP user name and password, it is used
+// only if credentials are not given in the URL. Provider is asked before each
+// login, so password can be rotated while streaming.
+func FTPCredentials(p CredentialsProvider) FTPOption {
+	return func(c *FTPPullClient) {
+		c.credentials = p
+	}
+}
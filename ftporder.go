@@ -0,0 +1,92 @@
+package wns
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// FTPFile describes odds document file available on the FTP server.
+type FTPFile struct {
+	Name string
+	Size uint64
+	// Time is the file modification time. Its precision depends on server
+	// support for MLSD and MDTM commands, with plain LIST it is often
+	// truncated to minutes.
+	Time time.Time
+}
+
+// FTPOrder reports whether file `a` was created before file `b`. It is used to
+// sort odds documents in chronological order.
+type FTPOrder func(a, b FTPFile) bool
+
+// FTPOrdering sets a strategy for sorting odds documents in chronological
+// order. Default is OrderByModTime.
+func FTPOrdering(order FTPOrder) FTPOption {
+	return func(c *FTPPullClient) {
+		c.order = order
+	}
+}
+
+// OrderByModTime sorts files by server modification time as primary key and
+// file name as secondary key.
+func OrderByModTime(a, b FTPFile) bool {
+	if a.Time.Equal(b.Time) {
+		return a.Name < b.Name
+	}
+	return a.Time.Before(b.Time)
+}
+
+// OrderByNameTimestamp returns ordering by timestamp embedded in file names.
+// Timestamp is extracted with the first capturing group of `pattern` and
+// parsed using `layout` (see time.Parse). Files without valid timestamp in
+// their names are sorted after the ones with it. Files with equal timestamps
+// and files without timestamps are ordered with OrderByModTime.
+func OrderByNameTimestamp(pattern *regexp.Regexp, layout string) FTPOrder {
+	parse := func(name string) (time.Time, bool) {
+		m := pattern.FindStringSubmatch(name)
+		if len(m) < 2 {
+			return time.Time{}, false
+		}
+		t, err := time.Parse(layout, m[1])
+		return t, err == nil
+	}
+	return func(a, b FTPFile) bool {
+		ta, okA := parse(a.Name)
+		tb, okB := parse(b.Name)
+		if okA != okB {
+			return okA
+		}
+		if !okA || ta.Equal(tb) {
+			return OrderByModTime(a, b)
+		}
+		return ta.Before(tb)
+	}
+}
+
+// OrderByNameSequence returns ordering by a sequence number embedded in file
+// names. Sequence number is extracted with the first capturing group of
+// `pattern`. Files without valid number in their names are sorted after the
+// ones with it. Files with equal sequence numbers and files without numbers
+// are ordered with OrderByModTime.
+func OrderByNameSequence(pattern *regexp.Regexp) FTPOrder {
+	parse := func(name string) (uint64, bool) {
+		m := pattern.FindStringSubmatch(name)
+		if len(m) < 2 {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(m[1], 10, 64)
+		return n, err == nil
+	}
+	return func(a, b FTPFile) bool {
+		na, okA := parse(a.Name)
+		nb, okB := parse(b.Name)
+		if okA != okB {
+			return okA
+		}
+		if !okA || na == nb {
+			return OrderByModTime(a, b)
+		}
+		return na < nb
+	}
+}
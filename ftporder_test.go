@@ -0,0 +1,78 @@
+package wns
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFTPOrder(t *testing.T) {
+	mtime := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	files := []FTPFile{
+		{Name: "wns_20220501120005_3.xml", Time: mtime},
+		{Name: "wns_20220501120001_10.xml", Time: mtime},
+		{Name: "wns_20220501120003_2.xml", Time: mtime.Add(-time.Minute)},
+		{Name: "other.xml", Time: mtime.Add(-2 * time.Minute)},
+		{Name: "extra.xml", Time: mtime.Add(-3 * time.Minute)},
+	}
+	tests := []struct {
+		msg      string
+		order    FTPOrder
+		expected []string
+	}{
+		{
+			msg:   "modification time",
+			order: OrderByModTime,
+			expected: []string{
+				"extra.xml",
+				"other.xml",
+				"wns_20220501120003_2.xml",
+				"wns_20220501120001_10.xml",
+				"wns_20220501120005_3.xml",
+			},
+		},
+		{
+			msg:   "name timestamp",
+			order: OrderByNameTimestamp(regexp.MustCompile(`^wns_(\d{14})_`), "20060102150405"),
+			expected: []string{
+				"wns_20220501120001_10.xml",
+				"wns_20220501120003_2.xml",
+				"wns_20220501120005_3.xml",
+				"extra.xml",
+				"other.xml",
+			},
+		},
+		{
+			msg:   "name sequence",
+			order: OrderByNameSequence(regexp.MustCompile(`_(\d+)\.xml$`)),
+			expected: []string{
+				"wns_20220501120003_2.xml",
+				"wns_20220501120005_3.xml",
+				"wns_20220501120001_10.xml",
+				"extra.xml",
+				"other.xml",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		// Ordering must not depend on input order.
+		for _, perm := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {3, 0, 4, 2, 1}} {
+			sorted := make([]FTPFile, 0, len(files))
+			for _, i := range perm {
+				sorted = append(sorted, files[i])
+			}
+			sort.Slice(sorted, func(i, j int) bool {
+				return test.order(sorted[i], sorted[j])
+			})
+			var actual []string
+			for _, f := range sorted {
+				actual = append(actual, f.Name)
+			}
+			assert.Equal(t, test.expected, actual, "%s %v", test.msg, perm)
+		}
+	}
+}
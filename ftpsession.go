@@ -0,0 +1,255 @@
+package wns
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// ftpSession is a logged in FTP connection bound to a context. Cancelling the
+// context closes network connections of the session, this way any in-flight
+// FTP command (e.g. stalled RETR) is interrupted.
+type ftpSession struct {
+	*ftp.ServerConn
+
+	ctx  context.Context
+	done chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	control net.Conn
+	data    net.Conn
+}
+
+// dial opens a new FTP connection and logs in. Returned session must be closed
+// with Close method.
+func (c *FTPPullClient) dial(ctx context.Context) (*ftpSession, error) {
+	cred := Credentials{Username: c.username, Password: c.password}
+	if c.credentials != nil && cred == (Credentials{}) {
+		var err error
+		if cred, err = getCredentials(ctx, c.credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &ftpSession{
+		ctx:  ctx,
+		done: make(chan struct{}),
+	}
+	go s.watch()
+
+	var tlsConfig *tls.Config
+	if c.secure {
+		tlsConfig = c.tlsConfig()
+	}
+	opts := []ftp.DialOption{
+		ftp.DialWithDialFunc(c.dialFunc(s, tlsConfig)),
+		ftp.DialWithDisabledEPSV(c.disableEPSV),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, ftp.DialWithExplicitTLS(tlsConfig))
+	}
+	conn, err := ftp.Dial(c.hostname, opts...)
+	if err != nil {
+		s.abort()
+		return nil, s.err(&Error{Kind: ErrTransport, Op: "dial", Err: err})
+	}
+	s.ServerConn = conn
+	if err = conn.Login(cred.Username, cred.Password); err != nil {
+		s.Close()
+		return nil, s.err(&Error{Kind: ftpErrKind(err), Op: "login", Err: err})
+	}
+	return s, nil
+}
+
+// dialFunc returns a function for dialing connections of a single FTP session.
+// The first dialed connection is a control connection, all the following ones
+// are data connections. Reads and writes on control connection are limited to
+// cmdTimeout each, so a silent server can not stall a command forever. Each
+// data connection is allowed to live for at most retrTimeout and is wrapped in
+// TLS if `tlsConfig` is not nil, because ftp package leaves data connections
+// created by custom dial function as is.
+func (c *FTPPullClient) dialFunc(s *ftpSession, tlsConfig *tls.Config) func(network, address string) (net.Conn, error) {
+	dial := c.dialContext
+	if dial == nil {
+		dialer := c.dialer
+		if dialer.Timeout == 0 {
+			dialer.Timeout = c.dialTimeout
+		}
+		if dialer.KeepAlive == 0 {
+			dialer.KeepAlive = c.dialTimeout
+		}
+		dial = dialer.DialContext
+	}
+	control := true
+	// controlHost and controlIP are set if control connection is not a TCP
+	// one. Data connections to controlIP, as reported to ftp package, are
+	// dialed using original host of control connection address instead.
+	var controlHost, controlIP string
+	return func(network, address string) (net.Conn, error) {
+		if !control && controlHost != "" {
+			if host, port, err := net.SplitHostPort(address); err == nil && host == controlIP {
+				address = net.JoinHostPort(controlHost, port)
+			}
+		}
+		conn, err := dial(s.ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if control {
+			if _, ok := conn.RemoteAddr().(*net.TCPAddr); !ok {
+				addr := tcpAddr(address)
+				conn = tcpAddrConn{Conn: conn, addr: addr}
+				controlHost, _, _ = net.SplitHostPort(address)
+				controlIP = addr.IP.String()
+			}
+			if c.cmdTimeout > 0 {
+				conn = deadlineConn{Conn: conn, timeout: c.cmdTimeout}
+			}
+		} else {
+			if c.retrTimeout > 0 {
+				if err := conn.SetDeadline(time.Now().Add(c.retrTimeout)); err != nil {
+					conn.Close()
+					return nil, err
+				}
+			}
+			if tlsConfig != nil {
+				conn = tls.Client(conn, tlsConfig)
+			}
+		}
+		if err := s.track(conn, control); err != nil {
+			return nil, err
+		}
+		control = false
+		return conn, nil
+	}
+}
+
+// deadlineConn sets a deadline of `timeout` before each read and write, this
+// way every FTP command and reply on control connection has its own deadline.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c deadlineConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c deadlineConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// tcpAddrConn reports remote address of a non TCP connection as TCP address,
+// because ftp package requires control connection to be a TCP one.
+type tcpAddrConn struct {
+	net.Conn
+	addr *net.TCPAddr
+}
+
+func (c tcpAddrConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// tcpAddr converts "host:port" address to TCP address without resolving host
+// names. Unspecified IP address is used as a placeholder for host names.
+func tcpAddr(address string) *net.TCPAddr {
+	host, port, _ := net.SplitHostPort(address)
+	addr := &net.TCPAddr{IP: net.ParseIP(host)}
+	if addr.IP == nil {
+		addr.IP = net.IPv4zero
+	}
+	addr.Port, _ = strconv.Atoi(port)
+	return addr
+}
+
+// tlsConfig returns TLS configuration for a single ftps session. Session cache
+// is enabled so data connections could resume TLS session of the control
+// connection, as many FTPS servers require it.
+func (c *FTPPullClient) tlsConfig() *tls.Config {
+	var cfg *tls.Config
+	if c.TLSConfig != nil {
+		cfg = c.TLSConfig.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(c.hostname)
+	}
+	if cfg.ClientSessionCache == nil {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	}
+	return cfg
+}
+
+// track registers a newly dialed connection. If session is already aborted
+// connection is closed and context error is returned.
+func (s *ftpSession) track(conn net.Conn, control bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		conn.Close()
+		return s.ctx.Err()
+	}
+	if control {
+		s.control = conn
+	} else {
+		s.data = conn
+	}
+	return nil
+}
+
+// watch aborts session when its context is cancelled.
+func (s *ftpSession) watch() {
+	select {
+	case <-s.ctx.Done():
+		s.abort()
+	case <-s.done:
+	}
+}
+
+// abort closes all network connections of the session without waiting for the
+// server to acknowledge it.
+func (s *ftpSession) abort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	if s.data != nil {
+		s.data.Close()
+	}
+	if s.control != nil {
+		s.control.Close()
+	}
+}
+
+// Close gracefully terminates FTP session.
+func (s *ftpSession) Close() {
+	if s.ctx.Err() == nil {
+		_ = s.ServerConn.Quit()
+	}
+	s.abort()
+}
+
+// err replaces network errors caused by aborting the session with context
+// error, so cancelled operations return bare context error.
+func (s *ftpSession) err(err error) error {
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
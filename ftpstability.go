@@ -0,0 +1,141 @@
+package wns
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"time"
+)
+
+// FTPStability configures detection of odds documents that are still being
+// uploaded to the FTP server. Files failing any of the checks are withheld
+// from listing together with all the files created after them, this way
+// Stream will not move its cursor past a file that is not complete yet.
+type FTPStability struct {
+	// Ignore is a list of file name patterns (in path.Match syntax) of
+	// temporary or partial files, e.g. "*.tmp" or "*.part". Matching
+	// files are never listed.
+	Ignore []string
+	// SizeCheckDelay enables comparing file sizes of two listings made
+	// SizeCheckDelay apart. Files with changed size or missing in the first
+	// listing are considered incomplete.
+	SizeCheckDelay time.Duration
+	// MinAge is a minimum age of file modification time for file to be
+	// considered complete. Server and client clocks should be in sync for
+	// this check to be useful.
+	MinAge time.Duration
+}
+
+// FTPStabilityCheck enables detection of incomplete uploads in List, Stream and
+// Snapshot methods.
+func FTPStabilityCheck(s FTPStability) FTPOption {
+	return func(c *FTPPullClient) {
+		c.stability = s
+	}
+}
+
+// listStable lists odds documents directory and filters out files that are
+// not uploaded completely.
+func (c *FTPPullClient) listStable(ctx context.Context, conn *ftpSession) ([]FTPFile, error) {
+	items, err := c.list(conn)
+	if err != nil {
+		return nil, err
+	}
+	var prev []FTPFile
+	if delay := c.stability.SizeCheckDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		prev = items
+		if items, err = c.list(conn); err != nil {
+			return nil, err
+		}
+	}
+	return c.stability.filter(prev, items, time.Now())
+}
+
+// filter removes ignored files from `items` and truncates it at the first
+// incomplete file. If `prev` is not nil it is used as the earlier listing for
+// size comparison.
+func (s FTPStability) filter(prev, items []FTPFile, now time.Time) ([]FTPFile, error) {
+	var sizes map[string]uint64
+	if prev != nil {
+		sizes = make(map[string]uint64, len(prev))
+		for _, item := range prev {
+			sizes[item.Name] = item.Size
+		}
+	}
+
+	files := make([]FTPFile, 0, len(items))
+	for _, item := range items {
+		ignored, err := s.ignored(item.Name)
+		if err != nil {
+			return nil, err
+		}
+		if ignored {
+			continue
+		}
+		if s.MinAge > 0 && now.Sub(item.Time) < s.MinAge {
+			break
+		}
+		if sizes != nil {
+			if size, ok := sizes[item.Name]; !ok || size != item.Size {
+				break
+			}
+		}
+		files = append(files, item)
+	}
+	return files, nil
+}
+
+func (s FTPStability) ignored(name string) (bool, error) {
+	for _, pattern := range s.Ignore {
+		match, err := path.Match(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// wellFormed checks if `bs` holds exactly one complete XML document. It catches
+// truncated documents that could otherwise be partially decoded.
+func wellFormed(bs []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(bs))
+	d.CharsetReader = charsetReader
+	depth := 0
+	root := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && root {
+				return errors.New("wns: multiple root elements in document")
+			}
+			root = true
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if !root {
+		return errors.New("wns: document has no root element")
+	}
+	return nil
+}
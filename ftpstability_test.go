@@ -0,0 +1,113 @@
+package wns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFTPStabilityFilter(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	items := []FTPFile{
+		{Name: "a.xml", Size: 10, Time: now.Add(-10 * time.Minute)},
+		{Name: "b.xml.tmp", Size: 5, Time: now.Add(-5 * time.Minute)},
+		{Name: "c.xml", Size: 20, Time: now.Add(-2 * time.Minute)},
+		{Name: "d.xml", Size: 30, Time: now.Add(-30 * time.Second)},
+	}
+	tests := []struct {
+		msg       string
+		stability FTPStability
+		prev      []FTPFile
+		expected  []string
+	}{
+		{
+			msg:       "no checks",
+			stability: FTPStability{},
+			expected:  []string{"a.xml", "b.xml.tmp", "c.xml", "d.xml"},
+		},
+		{
+			msg:       "ignored pattern",
+			stability: FTPStability{Ignore: []string{"*.tmp", "*.part"}},
+			expected:  []string{"a.xml", "c.xml", "d.xml"},
+		},
+		{
+			msg:       "minimum age",
+			stability: FTPStability{MinAge: time.Minute},
+			expected:  []string{"a.xml", "b.xml.tmp", "c.xml"},
+		},
+		{
+			msg:       "size changed",
+			stability: FTPStability{Ignore: []string{"*.tmp"}},
+			prev: []FTPFile{
+				{Name: "a.xml", Size: 10},
+				{Name: "c.xml", Size: 15},
+				{Name: "d.xml", Size: 30},
+			},
+			expected: []string{"a.xml"},
+		},
+		{
+			msg:       "new file",
+			stability: FTPStability{Ignore: []string{"*.tmp"}},
+			prev: []FTPFile{
+				{Name: "a.xml", Size: 10},
+				{Name: "c.xml", Size: 20},
+			},
+			expected: []string{"a.xml", "c.xml"},
+		},
+	}
+
+	for _, test := range tests {
+		files, err := test.stability.filter(test.prev, items, now)
+		require.NoError(t, err, test.msg)
+		actual := []string{}
+		for _, f := range files {
+			actual = append(actual, f.Name)
+		}
+		assert.Equal(t, test.expected, actual, test.msg)
+	}
+
+	_, err := FTPStability{Ignore: []string{"["}}.filter(nil, items, now)
+	assert.Error(t, err)
+}
+
+func TestWellFormed(t *testing.T) {
+	tests := []struct {
+		msg   string
+		xml   string
+		valid bool
+	}{
+		{
+			msg:   "complete document",
+			xml:   testDoc("now"),
+			valid: true,
+		},
+		{
+			msg:   "latin1 document",
+			xml:   `<?xml version="1.0" encoding="ISO-8859-1"?><BetradarBetData></BetradarBetData>`,
+			valid: true,
+		},
+		{
+			msg: "truncated document",
+			xml: `<?xml version="1.0" encoding="UTF-8"?><BetradarBetData><Timestamp/>`,
+		},
+		{
+			msg: "empty document",
+			xml: "",
+		},
+		{
+			msg: "trailing element",
+			xml: `<BetradarBetData></BetradarBetData><BetradarBetData>`,
+		},
+	}
+
+	for _, test := range tests {
+		err := wellFormed([]byte(test.xml))
+		if test.valid {
+			assert.NoError(t, err, test.msg)
+		} else {
+			assert.Error(t, err, test.msg)
+		}
+	}
+}
@@ -4,19 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
 	"time"
 
 	"golang.org/x/text/encoding/charmap"
 )
 
-var debug = os.Getenv("WNS_DEBUG") != ""
-
 // HTTPPullClient is Betradar WNS feed client for feed consumption with
 // HTTP-pull delivery method.
 type HTTPPullClient struct {
@@ -24,35 +24,79 @@ type HTTPPullClient struct {
 	Key        string      // API Key
 	URL        string      // URL of feed source
 	HTTPClient http.Client // If not specified, http.DefaultClient will be used
+	// Credentials, if set, supplies bookmaker name and key when Username
+	// and Key fields are empty. It is asked before each request, so key
+	// can be rotated while streaming.
+	Credentials CredentialsProvider
 	// Interval is a duration of how frequently it should be retried to fetch
 	// the feed. As the WNS does a rate limit for 1 request per 10 seconds,
 	// it is advisable to not set Interval to less then 10s.
 	Interval time.Duration
+	// Recorder, if set, is called with every received odds document.
+	Recorder Recorder
+	// SpoolDir, if set, enables crash-safe streaming. Stream writes each
+	// received document to this directory and syncs it to disk before
+	// handing it out. Document is removed from the spool once consumer
+	// calls Data.Ack, unacknowledged documents are redelivered first when
+	// Stream is started again.
+	SpoolDir string
+	// Drain enables queue drain mode. Instead of fetching one document per
+	// Interval, stream keeps fetching documents as fast as WNS rate limit
+	// allows until the queue is empty, then falls back to polling every
+	// Interval. Documents fetched while draining have Data.Drained set and
+	// StatusDrained event is reported once the queue is emptied.
+	Drain bool
+	// Retry is a policy for retrying failed requests. Retries are spaced
+	// out to respect WNS rate limit too. By default requests are not
+	// retried.
+	Retry RetryPolicy
 }
 
-// Clear clears the queue (removes all queued old lottery feed files)
+// Clear clears the queue (removes all queued old lottery feed files). Request
+// waits for the shared rate limiter, like requests of streams do.
 func (c *HTTPPullClient) Clear(ctx context.Context) error {
-	url := fmt.Sprintf("%s?bookmakerName=%s&key=%s&xmlFeedName=FileGet&deleteFullQueue=yes", c.URL, c.Username, c.Key)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	cred, err := c.credentials(ctx)
 	if err != nil {
 		return err
 	}
-	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	if !sharedLimiter(c.URL, cred.Username).wait(ctx) {
+		return ctx.Err()
+	}
+	req, err := c.request(ctx, cred, "deleteFullQueue=yes")
 	if err != nil {
 		return err
 	}
-	return resp.Body.Close()
-}
-
-// Data wraps BetradarBetData and error to be returned via streamed channel
-type Data struct {
-	Data     BetradarBetData
-	Filename string
-	Error    error
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return transportErr(ctx, "clear", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return transportErr(ctx, "clear", err)
+	}
+	return checkResponse(resp, bs)
 }
 
 // Stream streams all updates to a returned channel. Under the hood it uses
-// Get method on WNS with delete set to `true`
+// Get method on WNS with delete set to `true`. See SpoolDir for guaranteeing
+// documents are not lost if process stops before handling them.
+//
+// Requests of all streams using the same URL and bookmaker name in the process
+// are spaced out to respect WNS rate limit of one request per 10 seconds. If
+// WNS still rejects a request as too frequent, stream backs off and reports it
+// as a StatusThrottled event instead of an error.
+//
+// If Drain is set, stream empties the queue as fast as the rate limit allows
+// on start and every time a document is received, see Drain for details.
+//
+// Failed requests are retried according to Retry policy, if retries do not
+// help the error is delivered and polling continues on the next tick.
+// Rejected credentials are delivered with StatusStopped and the stream is
+// closed.
 func (c *HTTPPullClient) Stream(ctx context.Context) <-chan Data {
 	ch := make(chan Data)
 	interval := c.Interval
@@ -61,68 +105,367 @@ func (c *HTTPPullClient) Stream(ctx context.Context) <-chan Data {
 	}
 	go func() {
 		defer close(ch)
+		if !c.streamSpooled(ctx, ch) {
+			return
+		}
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
+		if c.Drain && !c.streamDrain(ctx, ch) {
+			return
+		}
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				d, err := c.Get(ctx, true)
-				if wnserror, ok := err.(*APIError); ok {
-					// if there are no new files, we skip streaming the
-					// struct with such error
-					if wnserror.Type == ErrTypeNoNew {
-						continue
-					}
+				var res pollResult
+				if c.Drain {
+					res = c.streamDrainPoll(ctx, ch)
+				} else {
+					res = c.streamPoll(ctx, ch, 0)
+				}
+				if res == pollStopped {
+					return
 				}
-				ch <- Data{Data: d, Error: err}
 			}
 		}
 	}()
 	return ch
 }
 
+// pollResult is an outcome of a single HTTP-pull stream poll.
+type pollResult int
+
+const (
+	pollReceived  pollResult = iota // document was received
+	pollEmpty                       // queue is empty
+	pollThrottled                   // request was rejected as too frequent
+	pollFailed                      // request failed
+	pollStopped                     // stream context was cancelled or credentials were rejected
+)
+
+// streamPoll fetches a single document and delivers it to the consumer.
+// Parameter `drained` is the number of documents fetched since queue drain
+// started, including this one, or zero when not draining.
+func (c *HTTPPullClient) streamPoll(ctx context.Context, ch chan<- Data, drained int) pollResult {
+	d, err := c.getData(ctx, true, true)
+	res := pollReceived
+	switch {
+	case errors.Is(err, ErrNoData):
+		// if there are no new files, we skip streaming the
+		// struct with such error
+		return pollEmpty
+	case errors.Is(err, ErrRateLimited):
+		wait := rateLimit
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			wait = apiErr.RetryAfter()
+		}
+		if !sendData(ctx, ch, Data{Status: StatusThrottled, Wait: wait}) {
+			return pollStopped
+		}
+		return pollThrottled
+	case !received(d, err):
+		if ctx.Err() != nil {
+			return pollStopped
+		}
+		if errors.Is(err, ErrAuth) {
+			d.Error = err
+			d.Status = StatusStopped
+			sendData(ctx, ch, d)
+			return pollStopped
+		}
+		res = pollFailed
+	case c.SpoolDir != "":
+		// Documents failing to parse or cut short are spooled too,
+		// as they are already deleted from the queue.
+		var spoolErr error
+		if d, spoolErr = c.spool().put(d); spoolErr != nil {
+			err = fmt.Errorf("wns: spooling document: %w", spoolErr)
+		}
+	}
+	d.Error = err
+	if res == pollReceived {
+		d.Drained = drained
+	}
+	if !sendData(ctx, ch, d) {
+		return pollStopped
+	}
+	return res
+}
+
+// streamDrainPoll polls the queue once and, if a document was received, keeps
+// draining it.
+func (c *HTTPPullClient) streamDrainPoll(ctx context.Context, ch chan<- Data) pollResult {
+	res := c.streamPoll(ctx, ch, 0)
+	if res != pollReceived {
+		return res
+	}
+	if !c.streamDrain(ctx, ch) {
+		return pollStopped
+	}
+	return pollEmpty
+}
+
+// streamDrain fetches documents as fast as rate limit allows until the queue
+// is empty. It returns false if stream should stop.
+func (c *HTTPPullClient) streamDrain(ctx context.Context, ch chan<- Data) bool {
+	drained := 0
+	for {
+		switch c.streamPoll(ctx, ch, drained+1) {
+		case pollReceived:
+			drained++
+		case pollThrottled:
+		case pollEmpty:
+			if drained == 0 {
+				return true
+			}
+			return sendData(ctx, ch, Data{Status: StatusDrained, Drained: drained})
+		case pollFailed:
+			// Queue is not known to be empty, keep draining at
+			// the rate limit pace.
+		case pollStopped:
+			return false
+		}
+	}
+}
+
+func (c *HTTPPullClient) spool() spool {
+	return spool{dir: c.SpoolDir, source: c.URL}
+}
+
+// streamSpooled redelivers unacknowledged documents from the spool. It returns
+// false if `ctx` was cancelled.
+func (c *HTTPPullClient) streamSpooled(ctx context.Context, ch chan<- Data) bool {
+	if c.SpoolDir == "" {
+		return true
+	}
+	docs, err := c.spool().pending()
+	if err != nil {
+		return sendData(ctx, ch, Data{Error: fmt.Errorf("wns: loading spool: %w", err)})
+	}
+	for _, d := range docs {
+		if !sendData(ctx, ch, d) {
+			return false
+		}
+	}
+	return true
+}
+
 // Get gets avialable lottery feed document
 // if delete flag is set to false, it does not delete the document from source,
 // so next Get request will return the same document until it is Get'ed with
 // delete flag set to true, or queue is cleared.
 func (c *HTTPPullClient) Get(ctx context.Context, delete bool) (BetradarBetData, error) {
+	d, err := c.GetData(ctx, delete)
+	if err != nil {
+		return BetradarBetData{}, err
+	}
+	return d.Data, nil
+}
+
+// GetData is like Get but returns document together with its raw payload and
+// metadata. If document was received, but could not be parsed or recorded,
+// returned Data holds the raw payload.
+func (c *HTTPPullClient) GetData(ctx context.Context, delete bool) (Data, error) {
+	return c.getData(ctx, delete, false)
+}
+
+// getData fetches a document, retrying failed requests according to Retry
+// policy. Retries wait for the shared rate limiter of the bookmaker, the
+// first request waits for it only if `limit` is set. Requests rejected as too
+// frequent throttle the limiter.
+func (c *HTTPPullClient) getData(ctx context.Context, delete, limit bool) (Data, error) {
+	var d Data
+	var err error
+	retryErr := c.Retry.Do(ctx, func(ctx context.Context) error {
+		var cred Credentials
+		if cred, err = c.credentials(ctx); err != nil {
+			d = Data{}
+			return err
+		}
+		limiter := sharedLimiter(c.URL, cred.Username)
+		if limit && !limiter.wait(ctx) {
+			return ctx.Err()
+		}
+		limit = true
+		d, err = c.get(ctx, cred, delete)
+		if delete && received(d, err) {
+			// Document is already removed from the queue, so
+			// it must not be refetched.
+			return nil
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Type == ErrTypeTooFrequent {
+			limiter.throttle(time.Now().Add(apiErr.RetryAfter()))
+		}
+		return err
+	})
+	if !received(d, err) {
+		err = retryErr
+	}
+	return d, err
+}
+
+// received reports whether get request returned an odds document, even if it
+// failed to parse or was cut short. Such document may already be removed from
+// the queue.
+func received(d Data, err error) bool {
+	if len(d.Raw) == 0 {
+		return false
+	}
+	var apiErr *APIError
+	return !errors.As(err, &apiErr) || apiErr.Type == ErrTypeTruncated
+}
+
+func (c *HTTPPullClient) get(ctx context.Context, cred Credentials, delete bool) (Data, error) {
 	keyword := "no"
 	if delete {
 		keyword = "yes"
 	}
-	url := fmt.Sprintf("%s?bookmakerName=%s&key=%s&xmlFeedName=FileGet&deleteAfterTransfer=%s", c.URL, c.Username, c.Key, keyword)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := c.request(ctx, cred, "deleteAfterTransfer="+keyword)
 	if err != nil {
-		return BetradarBetData{}, err
+		return Data{}, err
 	}
-	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return BetradarBetData{}, err
+		return Data{}, transportErr(ctx, "get", err)
 	}
 	defer resp.Body.Close()
 	bs, err := ioutil.ReadAll(resp.Body)
+	d := newData(bs, c.URL, "")
 	if err != nil {
-		return BetradarBetData{}, err
+		// Partially read document is returned, so it is not lost if
+		// it is already removed from the queue.
+		return d, transportErr(ctx, "get", err)
+	}
+	if err = checkResponse(resp, bs); err != nil {
+		return d, err
 	}
+	if err = record(c.Recorder, d); err != nil {
+		return d, &Error{Op: "get", Err: err}
+	}
+	if err = d.decode(); err != nil {
+		return d, responseErr(resp, bs, err)
+	}
+	return d, nil
+}
 
-	if debug {
-		_ = ioutil.WriteFile(fmt.Sprintf("wns-debug-%d.xml", time.Now().Unix()), bs, 0644)
+// credentials returns bookmaker name and key from Username and Key fields or,
+// if they are empty, from Credentials provider.
+func (c *HTTPPullClient) credentials(ctx context.Context) (Credentials, error) {
+	cred := Credentials{Username: c.Username, Password: c.Key}
+	if c.Credentials != nil && cred == (Credentials{}) {
+		return getCredentials(ctx, c.Credentials)
 	}
+	return cred, nil
+}
 
-	if err = checkForErr(bytes.NewBuffer(bs)); err != nil {
-		return BetradarBetData{}, err
+// request creates feed request with credentials and additional `params`.
+func (c *HTTPPullClient) request(ctx context.Context, cred Credentials, params string) (*http.Request, error) {
+	feedURL := fmt.Sprintf("%s?bookmakerName=%s&key=%s&xmlFeedName=FileGet&%s", c.URL, url.QueryEscape(cred.Username), url.QueryEscape(cred.Password), params)
+	req, err := http.NewRequest(http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, redactErr(err)
 	}
-	var data BetradarBetData
-	d := xml.NewDecoder(bytes.NewBuffer(bs))
-	d.CharsetReader = charsetReader
-	if err = d.Decode(&data); err != nil {
-		return BetradarBetData{}, err
+	return req.WithContext(ctx), nil
+}
+
+// transportErr wraps failed HTTP request error, credentials are redacted
+// from request URL. If request failed because `ctx` was cancelled, bare
+// context error is returned.
+func transportErr(ctx context.Context, op string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return &Error{Kind: ErrTransport, Op: op, Err: redactErr(err)}
+}
+
+// checkResponse classifies failed HTTP responses and WNS error documents.
+func checkResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &APIError{
+			Type:       ErrTypeAuth,
+			Err:        resp.Status,
+			StatusCode: resp.StatusCode,
+			Body:       excerpt(body),
+		}
+	}
+	if resp.StatusCode >= 500 {
+		return &APIError{
+			Type:       ErrTypeServer,
+			Err:        resp.Status,
+			StatusCode: resp.StatusCode,
+			Body:       excerpt(body),
+		}
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &APIError{
+			Type:       ErrTypeTruncated,
+			Err:        "empty response body",
+			StatusCode: resp.StatusCode,
+		}
+	}
+	if !isXML(resp.Header.Get("Content-Type"), body) {
+		return &APIError{
+			Type:       ErrTypeContentType,
+			Err:        resp.Header.Get("Content-Type"),
+			StatusCode: resp.StatusCode,
+			Body:       excerpt(body),
+		}
+	}
+	if err := checkForErr(bytes.NewReader(body)); err != nil {
+		return responseErr(resp, body, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{
+			Type:       ErrTypeUnknown,
+			Err:        resp.Status,
+			StatusCode: resp.StatusCode,
+			Body:       excerpt(body),
+		}
+	}
+	return nil
+}
+
+// responseErr attaches HTTP response details to errors returned by
+// checkForErr or document decoder. Incomplete XML errors are reported as
+// truncated response, other decoding errors are of ErrDecode kind.
+func responseErr(resp *http.Response, body []byte, err error) error {
+	if apiErr, ok := err.(*APIError); ok {
+		apiErr.StatusCode = resp.StatusCode
+		return apiErr
+	}
+	var syntaxErr *xml.SyntaxError
+	if err == io.EOF || err == io.ErrUnexpectedEOF || (errors.As(err, &syntaxErr) && syntaxErr.Msg == "unexpected EOF") {
+		return &APIError{
+			Type:       ErrTypeTruncated,
+			Err:        err.Error(),
+			StatusCode: resp.StatusCode,
+			Body:       excerpt(body),
+			cause:      err,
+		}
+	}
+	return &Error{Kind: ErrDecode, Op: "get", Err: err}
+}
+
+// isXML reports whether response looks like XML document. Responses labeled
+// with non XML content type are still accepted if body looks like XML, as
+// servers often use generic content types for XML documents.
+func isXML(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && strings.Contains(mediaType, "xml") {
+		return true
+	}
+	body = bytes.ToLower(bytes.TrimSpace(body))
+	if !bytes.HasPrefix(body, []byte("<")) {
+		return false
 	}
-	return data, nil
+	return !bytes.HasPrefix(body, []byte("<!doctype html")) && !bytes.HasPrefix(body, []byte("<html"))
 }
 
+// checkForErr detects WNS error documents. Only documented messages are
+// classified, other errors are of ErrTypeUnknown type.
 func checkForErr(r io.Reader) error {
 	var betradarErrors struct {
 		XMLName xml.Name
@@ -148,6 +491,8 @@ func checkForErr(r io.Reader) error {
 				Err:  betradarErrors.Val,
 			}
 		}
+		// Other messages are not documented by WNS, they are not
+		// guessed from their wording and are treated as temporary.
 		return &APIError{
 			Type: ErrTypeUnknown,
 			Err:  betradarErrors.Val,
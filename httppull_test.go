@@ -1,12 +1,21 @@
 package wns
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCheckForErrs(t *testing.T) {
@@ -31,6 +40,14 @@ func TestCheckForErrs(t *testing.T) {
 				Err:  "There are no files ready for transfer at the moment.",
 			},
 		},
+		{
+			msg: "test undocumented error",
+			xml: `<?xml version="1.0" encoding="ISO-8859-1"?><error>Invalid key</error>`,
+			expected: &APIError{
+				Type: ErrTypeUnknown,
+				Err:  "Invalid key",
+			},
+		},
 		{
 			msg:      "test random tag",
 			xml:      `<?xml version="1.0" encoding="ISO-8859-1"?><random>Some random content</random>`,
@@ -53,3 +70,299 @@ func TestCheckForErrs(t *testing.T) {
 		assert.Equal(t, test.expected, actual, test.msg)
 	}
 }
+
+func TestCheckResponse(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?><BetradarBetData></BetradarBetData>`
+	tests := []struct {
+		msg         string
+		status      int
+		contentType string
+		body        string
+		expected    ErrType
+		ok          bool
+	}{
+		{
+			msg:         "valid document",
+			status:      http.StatusOK,
+			contentType: "text/xml",
+			body:        doc,
+			ok:          true,
+		},
+		{
+			msg:         "valid document with generic content type",
+			status:      http.StatusOK,
+			contentType: "text/html; charset=UTF-8",
+			body:        doc,
+			ok:          true,
+		},
+		{
+			msg:         "unauthorized",
+			status:      http.StatusForbidden,
+			contentType: "text/html",
+			body:        "<html>Forbidden</html>",
+			expected:    ErrTypeAuth,
+		},
+		{
+			msg:         "server error",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        "<html><body>502 Bad Gateway</body></html>",
+			expected:    ErrTypeServer,
+		},
+		{
+			msg:         "empty body",
+			status:      http.StatusOK,
+			contentType: "text/xml",
+			body:        "  ",
+			expected:    ErrTypeTruncated,
+		},
+		{
+			msg:         "html page",
+			status:      http.StatusOK,
+			contentType: "text/html",
+			body:        "<!DOCTYPE html><html><body>Maintenance</body></html>",
+			expected:    ErrTypeContentType,
+		},
+		{
+			msg:         "truncated document",
+			status:      http.StatusOK,
+			contentType: "text/xml",
+			body:        `<?xml version="1.0" encoding="UTF-8"?><BetradarBetData><Sports>`,
+			expected:    ErrTypeTruncated,
+		},
+		{
+			msg:         "no new files",
+			status:      http.StatusOK,
+			contentType: "text/xml",
+			body:        `<error-message>There are no files ready for transfer at the moment.</error-message>`,
+			expected:    ErrTypeNoNew,
+		},
+		{
+			msg:         "not found",
+			status:      http.StatusNotFound,
+			contentType: "text/xml",
+			body:        doc,
+			expected:    ErrTypeUnknown,
+		},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{
+			Status:     http.StatusText(test.status),
+			StatusCode: test.status,
+			Header:     http.Header{"Content-Type": []string{test.contentType}},
+		}
+		err := checkResponse(resp, []byte(test.body))
+		if test.ok {
+			assert.NoError(t, err, test.msg)
+			continue
+		}
+		var apiErr *APIError
+		require.True(t, errors.As(err, &apiErr), test.msg)
+		assert.Equal(t, test.expected, apiErr.Type, test.msg)
+		assert.Equal(t, test.status, apiErr.StatusCode, test.msg)
+		assert.True(t, errors.Is(err, &APIError{Type: test.expected}), test.msg)
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	err := checkResponse(resp, []byte(`<BetradarBetData><Sports>`))
+	var syntaxErr *xml.SyntaxError
+	assert.True(t, errors.As(err, &syntaxErr), "truncated response error wraps XML error")
+	assert.False(t, errors.Is(err, &APIError{Type: ErrTypeServer}))
+}
+
+func TestHTTPPullGetData(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="ISO-8859-1"?><BetradarBetData DocumentType="Results"><Timestamp CreatedTime="now" TimeZone="UTC"/></BetradarBetData>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bookie", r.URL.Query().Get("bookmakerName"))
+		assert.Equal(t, "secret", r.URL.Query().Get("key"))
+		assert.Equal(t, "no", r.URL.Query().Get("deleteAfterTransfer"))
+		_, _ = io.WriteString(w, doc)
+	}))
+	defer srv.Close()
+
+	c := HTTPPullClient{
+		Username: "bookie",
+		Key:      "secret",
+		URL:      srv.URL,
+	}
+	d, err := c.GetData(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "Results", d.Data.Type)
+	assert.Equal(t, []byte(doc), d.Raw)
+	assert.Equal(t, len(doc), d.Size)
+	assert.Equal(t, srv.URL, d.Source)
+	assert.Equal(t, "ISO-8859-1", d.Charset)
+}
+
+func TestHTTPPullStreamSpool(t *testing.T) {
+	setTestRateLimit(t, time.Millisecond)
+
+	var queue []string
+	for _, created := range []string{"a", "b", "c"} {
+		queue = append(queue, `<?xml version="1.0" encoding="UTF-8"?><BetradarBetData><Timestamp CreatedTime="`+created+`"/></BetradarBetData>`)
+	}
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(queue) == 0 {
+			_, _ = io.WriteString(w, `<error-message>There are no files ready for transfer at the moment.</error-message>`)
+			return
+		}
+		_, _ = io.WriteString(w, queue[0])
+		queue = queue[1:]
+	}))
+	defer srv.Close()
+
+	c := HTTPPullClient{
+		URL:      srv.URL,
+		Interval: 10 * time.Millisecond,
+		SpoolDir: t.TempDir(),
+	}
+
+	// First document is processed, second one is received but process
+	// stops before acknowledging it.
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := c.Stream(ctx)
+	msg := <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "a", msg.Data.Timestamp.Created)
+	require.NoError(t, msg.Ack())
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "b", msg.Data.Timestamp.Created)
+	cancel()
+	for range stream {
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	stream = c.Stream(ctx)
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "b", msg.Data.Timestamp.Created)
+	require.NoError(t, msg.Ack())
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "c", msg.Data.Timestamp.Created)
+	require.NoError(t, msg.Ack())
+	cancel()
+	for range stream {
+	}
+}
+
+func TestHTTPPullStreamSpoolTruncated(t *testing.T) {
+	setTestRateLimit(t, time.Millisecond)
+
+	doc := `<BetradarBetData><Timestamp CreatedTime="a"/></BetradarBetData>`
+	tests := []struct {
+		msg     string
+		handler http.HandlerFunc
+	}{
+		{
+			msg: "connection closed mid-read",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(doc)))
+				_, _ = io.WriteString(w, doc[:20])
+			},
+		},
+		{
+			msg: "truncated document",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, doc[:20])
+			},
+		},
+	}
+	for _, test := range tests {
+		var requests int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) > 1 {
+				_, _ = io.WriteString(w, `<error-message>There are no files ready for transfer at the moment.</error-message>`)
+				return
+			}
+			test.handler(w, r)
+		}))
+
+		c := HTTPPullClient{
+			URL:      srv.URL,
+			Interval: time.Hour,
+			Drain:    true,
+			SpoolDir: t.TempDir(),
+			Retry:    RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := c.Stream(ctx)
+		msg := <-stream
+		assert.Error(t, msg.Error, test.msg)
+		assert.Equal(t, []byte(doc[:20]), msg.Raw, test.msg)
+		msg = <-stream
+		assert.Equal(t, StatusDrained, msg.Status, test.msg)
+		cancel()
+		for range stream {
+		}
+		srv.Close()
+
+		pending, err := c.spool().pending()
+		require.NoError(t, err, test.msg)
+		require.Len(t, pending, 1, test.msg)
+		assert.Equal(t, []byte(doc[:20]), pending[0].Raw, test.msg)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "%s: document must not be refetched", test.msg)
+	}
+}
+
+func TestHTTPPullStreamDrain(t *testing.T) {
+	setTestRateLimit(t, time.Millisecond)
+
+	var mu sync.Mutex
+	queued := 5
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if queued == 0 {
+			_, _ = io.WriteString(w, `<error-message>There are no files ready for transfer at the moment.</error-message>`)
+			return
+		}
+		queued--
+		_, _ = io.WriteString(w, `<BetradarBetData><Timestamp CreatedTime="doc"/></BetradarBetData>`)
+	}))
+	defer srv.Close()
+
+	// Interval is long enough for no regular polls to happen during test.
+	c := HTTPPullClient{URL: srv.URL, Interval: time.Hour, Drain: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := c.Stream(ctx)
+
+	for i := 1; i <= 5; i++ {
+		msg := <-stream
+		require.NoError(t, msg.Error)
+		assert.Equal(t, StatusDocument, msg.Status)
+		assert.Equal(t, i, msg.Drained)
+	}
+	msg := <-stream
+	assert.Equal(t, StatusDrained, msg.Status)
+	assert.Equal(t, 5, msg.Drained)
+
+	cancel()
+	for range stream {
+	}
+}
+
+func TestHTTPPullGetDataErrors(t *testing.T) {
+	body := `<BetradarBetData><Timestamp></BetradarBetData>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	c := HTTPPullClient{URL: srv.URL}
+	d, err := c.GetData(context.Background(), true)
+	assert.True(t, errors.Is(err, ErrDecode), err)
+	assert.False(t, Retryable(err))
+	assert.Equal(t, []byte(body), d.Raw)
+
+	srv.Close()
+	_, err = c.GetData(context.Background(), true)
+	assert.True(t, errors.Is(err, ErrTransport), err)
+	assert.True(t, Retryable(err))
+}
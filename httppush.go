@@ -0,0 +1,244 @@
+package wns
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPPushHandler is Betradar WNS feed receiver for HTTP-push delivery method.
+// It is an http.Handler accepting odds documents POSTed by WNS, received
+// documents are delivered to the channel returned by Stream.
+//
+// Handler answers a push request only after the document is handed over to the
+// stream consumer, this way slow consumer slows down the sender instead of
+// documents piling up in memory. If there is no consumer or it does not take
+// the document within Timeout, request is rejected with 503 status and WNS is
+// expected to deliver the document again later.
+//
+// AckBody must be set. HTTPPushHandler with only AckBody set accepts documents
+// from anyone, set Secret or AllowedIPs to authenticate the sender.
+type HTTPPushHandler struct {
+	// Secret, if set, must be presented by the sender either as `key`
+	// query parameter of push URL or as HTTP basic auth password.
+	Secret string
+	// AllowedIPs, if set, limits senders to the listed IP addresses or
+	// CIDR networks, e.g. "192.0.2.10" or "192.0.2.0/24". Invalid entries
+	// match nothing. Sender address is taken from the connection, so
+	// handler must not be placed behind a proxy if this is used.
+	AllowedIPs []string
+	// AckBody is the response body acknowledging accepted document. WNS
+	// does not publish it, use the acknowledgement agreed with Betradar
+	// for the push endpoint. It is required, requests are rejected with
+	// 500 status if it is empty.
+	AckBody string
+	// Timeout is the maximum amount of time to wait for the consumer to
+	// take received document. Default is 30 seconds.
+	Timeout time.Duration
+	// MaxSize is the maximum accepted document size in bytes. Default is
+	// 16 MiB.
+	MaxSize int64
+	// Recorder, if set, is called with every odds document taken by the
+	// stream consumer. Recording failure rejects the push request with 500
+	// status, so the document is pushed again.
+	Recorder Recorder
+
+	mu     sync.Mutex
+	stream *pushStream
+}
+
+const defaultPushTimeout = 30 * time.Second
+const defaultPushMaxSize = 16 << 20
+
+// Stream returns a channel of pushed odds documents. Channel is closed when
+// `ctx` is cancelled. Only one stream can be active at a time, calling Stream
+// again closes the previous stream.
+//
+// Documents failing to parse are delivered with Error set and their raw
+// payload, as they are already acknowledged to the sender.
+func (h *HTTPPushHandler) Stream(ctx context.Context) <-chan Data {
+	s := &pushStream{
+		ch:   make(chan Data),
+		done: make(chan struct{}),
+	}
+	h.mu.Lock()
+	prev := h.stream
+	h.stream = s
+	h.mu.Unlock()
+	if prev != nil {
+		prev.close()
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+			return
+		}
+		h.mu.Lock()
+		if h.stream == s {
+			h.stream = nil
+		}
+		h.mu.Unlock()
+		s.close()
+	}()
+	return s.ch
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *HTTPPushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.AckBody == "" {
+		http.Error(w, "acknowledgement body is not configured", http.StatusInternalServerError)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.allowedIP(r.RemoteAddr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	maxSize := h.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultPushMaxSize
+	}
+	bs, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	if err != nil {
+		http.Error(w, "reading document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(bytes.TrimSpace(bs)) == 0 || !isXML(r.Header.Get("Content-Type"), bs) {
+		http.Error(w, "XML document expected", http.StatusBadRequest)
+		return
+	}
+
+	d := newData(bs, r.RemoteAddr, "")
+	if err := d.decode(); err != nil {
+		d.Error = &Error{Kind: ErrDecode, Op: "push", Err: err}
+	}
+
+	if !h.deliver(r.Context(), d) {
+		http.Error(w, "document not accepted, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	// Document is recorded only once it is delivered, rejected pushes
+	// are repeated by the sender and would be recorded twice.
+	if err := record(h.Recorder, d); err != nil {
+		http.Error(w, "recording document failed", http.StatusInternalServerError)
+		return
+	}
+	ack := h.AckBody
+	if strings.HasPrefix(ack, "<") {
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	_, _ = w.Write([]byte(ack))
+}
+
+// deliver hands over `d` to the active stream consumer. It returns false if
+// there is no consumer or it did not take the document in time.
+func (h *HTTPPushHandler) deliver(ctx context.Context, d Data) bool {
+	h.mu.Lock()
+	s := h.stream
+	h.mu.Unlock()
+	if s == nil {
+		return false
+	}
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = defaultPushTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return s.send(ctx, d)
+}
+
+// authorized reports whether request carries the shared secret.
+func (h *HTTPPushHandler) authorized(r *http.Request) bool {
+	if h.Secret == "" {
+		return true
+	}
+	secret := r.URL.Query().Get("key")
+	if _, password, ok := r.BasicAuth(); ok {
+		secret = password
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(h.Secret)) == 1
+}
+
+// allowedIP reports whether request from `remoteAddr` is allowed by
+// AllowedIPs list.
+func (h *HTTPPushHandler) allowedIP(remoteAddr string) bool {
+	if len(h.AllowedIPs) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range h.AllowedIPs {
+		if strings.IndexByte(allowed, '/') != -1 {
+			if _, network, err := net.ParseCIDR(allowed); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// pushStream is a channel of a single HTTPPushHandler.Stream call. Senders
+// hold read lock while sending, so the channel is never closed under them.
+type pushStream struct {
+	ch   chan Data
+	done chan struct{}
+	once sync.Once
+	mu   sync.RWMutex
+}
+
+// send delivers `d` to the consumer. It returns false if stream was closed or
+// `ctx` was cancelled before consumer received the document.
+func (s *pushStream) send(ctx context.Context, d Data) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	select {
+	case s.ch <- d:
+		return true
+	case <-s.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *pushStream) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
@@ -0,0 +1,176 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPPushAuth(t *testing.T) {
+	h := &HTTPPushHandler{
+		Secret:     "secret",
+		AllowedIPs: []string{"192.0.2.10", "198.51.100.0/24", "invalid"},
+		AckBody:    "OK",
+		Timeout:    time.Millisecond,
+	}
+	tests := []struct {
+		msg        string
+		method     string
+		url        string
+		remoteAddr string
+		password   string
+		status     int
+	}{
+		{
+			msg:        "key parameter",
+			url:        "/push?key=secret",
+			remoteAddr: "192.0.2.10:1234",
+			status:     http.StatusServiceUnavailable, // authorized, but nobody streams
+		},
+		{
+			msg:        "basic auth",
+			url:        "/push",
+			remoteAddr: "198.51.100.7:1234",
+			password:   "secret",
+			status:     http.StatusServiceUnavailable,
+		},
+		{
+			msg:        "wrong key",
+			url:        "/push?key=wrong",
+			remoteAddr: "192.0.2.10:1234",
+			status:     http.StatusUnauthorized,
+		},
+		{
+			msg:        "no key",
+			url:        "/push",
+			remoteAddr: "192.0.2.10:1234",
+			status:     http.StatusUnauthorized,
+		},
+		{
+			msg:        "IP not allowed",
+			url:        "/push?key=secret",
+			remoteAddr: "192.0.2.11:1234",
+			status:     http.StatusForbidden,
+		},
+		{
+			msg:        "method",
+			method:     http.MethodGet,
+			url:        "/push?key=secret",
+			remoteAddr: "192.0.2.10:1234",
+			status:     http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		method := test.method
+		if method == "" {
+			method = http.MethodPost
+		}
+		r := httptest.NewRequest(method, test.url, strings.NewReader(testDoc("now")))
+		r.RemoteAddr = test.remoteAddr
+		if test.password != "" {
+			r.SetBasicAuth("wns", test.password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, test.status, w.Code, test.msg)
+	}
+}
+
+func TestHTTPPushStream(t *testing.T) {
+	h := &HTTPPushHandler{AckBody: "<ack/>"}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := h.Stream(ctx)
+
+	push := func(body string) (*http.Response, error) {
+		resp, err := http.Post(srv.URL, "text/xml", strings.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	doc := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><BetradarBetData><Timestamp CreatedTime=\"f\xfcnf\"/></BetradarBetData>"
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		resp, err := push(doc)
+		done <- result{resp, err}
+	}()
+	msg := <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "fünf", msg.Data.Timestamp.Created)
+	assert.Equal(t, "ISO-8859-1", msg.Charset)
+	assert.Equal(t, []byte(doc), msg.Raw)
+	res := <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.resp.StatusCode)
+	assert.Equal(t, "text/xml; charset=utf-8", res.resp.Header.Get("Content-Type"))
+
+	go func() {
+		resp, err := push("<BetradarBetData>")
+		done <- result{resp, err}
+	}()
+	msg = <-stream
+	assert.True(t, errors.Is(msg.Error, ErrDecode), msg.Error)
+	res = <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.resp.StatusCode)
+
+	resp, err := http.Post(srv.URL, "text/html", strings.NewReader("<html>Error page</html>"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	cancel()
+	for range stream {
+	}
+	h.Timeout = time.Millisecond
+	resp, err = push(doc)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestHTTPPushRecorder(t *testing.T) {
+	r := &testRecorder{}
+	h := &HTTPPushHandler{AckBody: "OK", Recorder: r, Timeout: time.Millisecond}
+	push := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(testDoc("a"))))
+		return w.Code
+	}
+
+	// Nobody streams, rejected document is not recorded.
+	assert.Equal(t, http.StatusServiceUnavailable, push())
+	assert.Empty(t, r.docs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.Timeout = 0
+	stream := h.Stream(ctx)
+	done := make(chan int)
+	go func() {
+		done <- push()
+	}()
+	msg := <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, http.StatusOK, <-done)
+	require.Len(t, r.docs, 1)
+	assert.Equal(t, msg.SHA256, r.docs[0].SHA256)
+
+	h.AckBody = ""
+	assert.Equal(t, http.StatusInternalServerError, push())
+}
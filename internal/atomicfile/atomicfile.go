@@ -0,0 +1,39 @@
+// Package atomicfile writes files atomically, so readers never see partially
+// written files.
+package atomicfile
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes a file via temporary file in the same directory. Content
+// written by `fn` is synced to disk and the temporary file is renamed to
+// `filename` with permissions `perm`, so the file appears under its final
+// name only when complete. Temporary files start with a dot and never match
+// the final name.
+func WriteFile(filename string, perm os.FileMode, fn func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
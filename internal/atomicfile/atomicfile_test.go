@@ -0,0 +1,44 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.xml")
+
+	err := WriteFile(filename, 0644, func(w io.Writer) error {
+		_, err := io.WriteString(w, "content")
+		return err
+	})
+	require.NoError(t, err)
+	bs, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(bs))
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	failure := errors.New("failure")
+	err = WriteFile(filename, 0644, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	bs, err = ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(bs), "failed write should keep old content")
+
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, infos, 1, "temporary files should be removed")
+}
@@ -0,0 +1,24 @@
+// Package sleep provides context aware sleeping.
+package sleep
+
+import (
+	"context"
+	"time"
+)
+
+// Until waits until wall clock reaches `t`. It returns false if `ctx` was
+// cancelled first.
+func Until(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
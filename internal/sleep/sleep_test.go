@@ -0,0 +1,21 @@
+package sleep
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUntil(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	assert.True(t, Until(ctx, start.Add(10*time.Millisecond)))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(10*time.Millisecond))
+	assert.True(t, Until(ctx, start))
+
+	cancel()
+	assert.False(t, Until(ctx, start))
+	assert.False(t, Until(ctx, time.Now().Add(time.Hour)))
+}
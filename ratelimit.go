@@ -0,0 +1,102 @@
+package wns
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimit is the minimum time between two WNS HTTP-pull requests made with
+// the same credentials.
+var rateLimit = 10 * time.Second
+
+var lastRequestRe = regexp.MustCompile(`(\d+) seconds? ago`)
+
+// RetryAfter returns how long to wait before making next request after "Too
+// frequent download" error. It is derived from the time of last request
+// reported by WNS. For other error types zero is returned.
+func (e *APIError) RetryAfter() time.Duration {
+	if e.Type != ErrTypeTooFrequent {
+		return 0
+	}
+	m := lastRequestRe.FindStringSubmatch(e.Err)
+	if m == nil {
+		return rateLimit
+	}
+	ago, err := strconv.Atoi(m[1])
+	if err != nil {
+		return rateLimit
+	}
+	// Clocks of WNS and ours are not in sync, keep at least some delay.
+	wait := rateLimit - time.Duration(ago)*time.Second
+	if min := rateLimit / 10; wait < min {
+		wait = min
+	}
+	return wait
+}
+
+// rateLimiter spaces out requests made with the same credentials.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// limiters holds rate limiters shared by all HTTP-pull clients of the process,
+// keyed by feed URL and bookmaker name.
+var limiters = struct {
+	sync.Mutex
+	m map[string]*rateLimiter
+}{m: make(map[string]*rateLimiter)}
+
+func sharedLimiter(url, username string) *rateLimiter {
+	key := url + "\x00" + username
+	limiters.Lock()
+	defer limiters.Unlock()
+	l, ok := limiters.m[key]
+	if !ok {
+		l = &rateLimiter{}
+		limiters.m[key] = l
+	}
+	return l
+}
+
+// reserve books the earliest available request slot and returns how long to
+// wait for it.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(rateLimit)
+	return wait
+}
+
+// wait blocks until the next request is allowed. It returns false if `ctx`
+// was cancelled while waiting.
+func (l *rateLimiter) wait(ctx context.Context) bool {
+	d := l.reserve(time.Now())
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// throttle postpones next request until `until`.
+func (l *rateLimiter) throttle(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.next) {
+		l.next = until
+	}
+}
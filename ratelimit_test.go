@@ -0,0 +1,134 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setTestRateLimit overrides WNS rate limit for the duration of the test.
+func setTestRateLimit(t *testing.T, d time.Duration) {
+	old := rateLimit
+	rateLimit = d
+	t.Cleanup(func() {
+		rateLimit = old
+	})
+}
+
+func TestAPIErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		msg      string
+		err      *APIError
+		expected time.Duration
+	}{
+		{
+			msg:      "3 seconds ago",
+			err:      &APIError{Type: ErrTypeTooFrequent, Err: "Too frequent download. (From IP: 127.0.0.1, 3 seconds ago)"},
+			expected: 7 * time.Second,
+		},
+		{
+			msg:      "1 second ago",
+			err:      &APIError{Type: ErrTypeTooFrequent, Err: "Too frequent download. (From IP: 127.0.0.1, 1 second ago)"},
+			expected: 9 * time.Second,
+		},
+		{
+			msg:      "limit almost passed",
+			err:      &APIError{Type: ErrTypeTooFrequent, Err: "Too frequent download. (From IP: 127.0.0.1, 10 seconds ago)"},
+			expected: time.Second,
+		},
+		{
+			msg:      "unknown last request time",
+			err:      &APIError{Type: ErrTypeTooFrequent, Err: "Too frequent download."},
+			expected: 10 * time.Second,
+		},
+		{
+			msg:      "other error",
+			err:      &APIError{Type: ErrTypeNoNew, Err: "There are no files ready for transfer at the moment."},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.err.RetryAfter(), test.msg)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := &rateLimiter{}
+	assert.Equal(t, time.Duration(0), l.reserve(now))
+	assert.Equal(t, 10*time.Second, l.reserve(now))
+	assert.Equal(t, 15*time.Second, l.reserve(now.Add(5*time.Second)))
+	assert.Equal(t, time.Duration(0), l.reserve(now.Add(time.Minute)))
+
+	l.throttle(now.Add(2 * time.Minute))
+	assert.Equal(t, 55*time.Second, l.reserve(now.Add(65*time.Second)))
+
+	assert.Same(t, sharedLimiter("https://wns", "a"), sharedLimiter("https://wns", "a"))
+	assert.NotSame(t, sharedLimiter("https://wns", "a"), sharedLimiter("https://wns", "b"))
+}
+
+func TestHTTPPullStreamThrottle(t *testing.T) {
+	setTestRateLimit(t, time.Millisecond)
+
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			_, _ = io.WriteString(w, `<error>Too frequent download. (From IP: 127.0.0.1, 3 seconds ago)</error>`)
+			return
+		}
+		_, _ = io.WriteString(w, `<BetradarBetData><Timestamp CreatedTime="a"/></BetradarBetData>`)
+	}))
+	defer srv.Close()
+
+	c := HTTPPullClient{URL: srv.URL, Interval: time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := c.Stream(ctx)
+
+	msg := <-stream
+	assert.NoError(t, msg.Error)
+	assert.Equal(t, StatusThrottled, msg.Status)
+	assert.Positive(t, msg.Wait)
+
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, StatusDocument, msg.Status)
+	assert.Equal(t, "a", msg.Data.Timestamp.Created)
+
+	cancel()
+	for range stream {
+	}
+}
+
+func TestHTTPPullSharedLimiterCredentials(t *testing.T) {
+	setTestRateLimit(t, time.Hour)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `<error>Too frequent download. (From IP: 127.0.0.1, 0 seconds ago)</error>`)
+	}))
+	defer srv.Close()
+
+	c := HTTPPullClient{URL: srv.URL, Credentials: rotating("bookie", "key")}
+	require.NoError(t, c.Clear(context.Background()))
+	now := time.Now()
+	assert.Positive(t, sharedLimiter(srv.URL, "bookie").reserve(now), "request is limited by resolved bookmaker name")
+	assert.Equal(t, time.Duration(0), sharedLimiter(srv.URL, "").reserve(now))
+
+	c.URL = srv.URL + "/throttle"
+	_, err := c.Get(context.Background(), false)
+	require.True(t, errors.Is(err, ErrRateLimited), err)
+	assert.Positive(t, sharedLimiter(c.URL, "bookie").reserve(now), "rejected request throttles limiter of resolved bookmaker name")
+	assert.Equal(t, time.Duration(0), sharedLimiter(c.URL, "").reserve(now))
+}
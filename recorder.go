@@ -0,0 +1,269 @@
+package wns
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/advbet/wns/internal/atomicfile"
+)
+
+// Recorder archives raw odds documents. Clients call Record for every received
+// document, `d` holds document payload and metadata. Pull clients record
+// documents before they are parsed, so Data field may not be filled yet.
+type Recorder interface {
+	Record(d Data) error
+}
+
+// record passes received document to recorder, if it is set.
+func record(r Recorder, d Data) error {
+	if r == nil {
+		return nil
+	}
+	if err := r.Record(d); err != nil {
+		return fmt.Errorf("wns: recording document: %w", err)
+	}
+	return nil
+}
+
+// recordTimeLayout is used for naming archived documents, so that lexical
+// order of file names matches the order documents were received in.
+const recordTimeLayout = "20060102T150405.000000000Z"
+
+// recordExt is the extension of archived documents, metadata sidecar files
+// have additional recordMetaExt extension.
+const recordExt = ".xml"
+const recordGzipExt = ".gz"
+const recordMetaExt = ".json"
+
+// FSRecorder is a Recorder storing documents in a local directory. Documents
+// are partitioned into YYYY/MM/DD folders by their receive time and named
+// after receive time and content hash, so documents received in the same
+// second do not collide. Each document has a JSON sidecar file with its
+// metadata.
+//
+// Zero value FSRecorder with Dir set is ready to use.
+type FSRecorder struct {
+	Dir      string        // Archive root directory
+	Compress bool          // Store documents gzip compressed
+	MaxAge   time.Duration // If non zero, remove documents older than MaxAge
+	MaxSize  int64         // If non zero, remove oldest documents to keep archive within MaxSize bytes
+
+	mu        sync.Mutex
+	lastPrune time.Time
+	pruning   bool  // Prune is running in background
+	pruneErr  error // Error of the last background Prune, see PruneErr
+}
+
+// pruneInterval limits how often archive retention is enforced by Record.
+const pruneInterval = time.Minute
+
+// recordMeta is the contents of document metadata sidecar file.
+type recordMeta struct {
+	Filename string    `json:"filename,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Charset  string    `json:"charset,omitempty"`
+	Received time.Time `json:"received"`
+	Size     int       `json:"size"`
+	SHA256   string    `json:"sha256"`
+}
+
+// Record implements Recorder interface. Returned error concerns only storing
+// `d`, failures of background Prune are reported by PruneErr.
+func (r *FSRecorder) Record(d Data) error {
+	received := d.Received
+	if received.IsZero() {
+		received = time.Now()
+	}
+	received = received.UTC()
+
+	dir := filepath.Join(r.Dir, received.Format("2006"), received.Format("01"), received.Format("02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := received.Format(recordTimeLayout) + "-" + shortHash(d.SHA256)
+	if base := sanitizeName(d.Filename); base != "" {
+		name += "-" + base
+	}
+	name += recordExt
+	if r.Compress {
+		name += recordGzipExt
+	}
+
+	meta, err := json.Marshal(recordMeta{
+		Filename: d.Filename,
+		Source:   d.Source,
+		Charset:  d.Charset,
+		Received: received,
+		Size:     d.Size,
+		SHA256:   d.SHA256,
+	})
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, name+recordMetaExt), 0600, func(w io.Writer) error {
+		_, err := w.Write(meta)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, name), 0600, func(w io.Writer) error {
+		if !r.Compress {
+			_, err := w.Write(d.Raw)
+			return err
+		}
+		gz := gzip.NewWriter(w)
+		if _, err := gz.Write(d.Raw); err != nil {
+			return err
+		}
+		return gz.Close()
+	}); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if (r.MaxAge > 0 || r.MaxSize > 0) && !r.pruning && time.Since(r.lastPrune) >= pruneInterval {
+		r.lastPrune = time.Now()
+		r.pruning = true
+		go r.backgroundPrune()
+	}
+	return nil
+}
+
+// backgroundPrune runs Prune off the Record call path. Its error is reported
+// by PruneErr.
+func (r *FSRecorder) backgroundPrune() {
+	err := r.Prune()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruning = false
+	r.pruneErr = err
+}
+
+// PruneErr returns error of the last Prune started in background by Record,
+// or nil if it succeeded or has not run yet. Archive keeps growing while
+// pruning fails, so long running processes should check it periodically.
+func (r *FSRecorder) PruneErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pruneErr
+}
+
+// Prune removes documents violating MaxAge or MaxSize retention settings.
+// Record starts it in background at most once per minute, so usually there is
+// no need to call it directly.
+func (r *FSRecorder) Prune() error {
+	docs, err := r.documents()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, doc := range docs {
+		total += doc.size
+	}
+	now := time.Now()
+	for _, doc := range docs {
+		expired := r.MaxAge > 0 && now.Sub(doc.received) > r.MaxAge
+		oversize := r.MaxSize > 0 && total > r.MaxSize
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(doc.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(doc.path + recordMetaExt); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= doc.size
+		removeEmptyDirs(r.Dir, filepath.Dir(doc.path))
+	}
+	return nil
+}
+
+// archivedDoc is a single document found in FSRecorder archive.
+type archivedDoc struct {
+	path     string
+	received time.Time
+	size     int64 // on disk size of document and metadata files
+}
+
+// documents lists archived documents in the order they were received.
+func (r *FSRecorder) documents() ([]archivedDoc, error) {
+	var docs []archivedDoc
+	err := filepath.Walk(r.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if !strings.HasSuffix(name, recordExt) && !strings.HasSuffix(name, recordExt+recordGzipExt) {
+			return nil
+		}
+		received, ok := parseRecordName(name)
+		if !ok {
+			return nil
+		}
+		size := info.Size()
+		if meta, err := os.Stat(p + recordMetaExt); err == nil {
+			size += meta.Size()
+		}
+		docs = append(docs, archivedDoc{path: p, received: received, size: size})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return path.Base(filepath.ToSlash(docs[i].path)) < path.Base(filepath.ToSlash(docs[j].path))
+	})
+	return docs, err
+}
+
+// parseRecordName extracts receive time from archived document file name.
+func parseRecordName(name string) (time.Time, bool) {
+	if len(name) < len(recordTimeLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(recordTimeLayout, name[:len(recordTimeLayout)])
+	return t, err == nil
+}
+
+// removeEmptyDirs removes `dir` and its parents up to, but not including,
+// `root` as long as they are empty.
+func removeEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// sanitizeName strips directories and extension from original document file
+// name, so it can be safely used as a part of archived file name.
+func sanitizeName(filename string) string {
+	base := path.Base(filepath.ToSlash(filename))
+	if base == "." || base == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(base, path.Ext(base))
+}
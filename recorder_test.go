@@ -0,0 +1,162 @@
+package wns
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r := &FSRecorder{Dir: dir, Compress: true}
+
+	received := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := newData([]byte(testDoc("a")), "ftp://ftp.betradar.com:21/wns/a.xml", "a.xml")
+	a.Received = received
+	b := newData([]byte(testDoc("b")), "ftp://ftp.betradar.com:21/wns/b.xml", "b.xml")
+	b.Received = received.Add(time.Millisecond)
+	require.NoError(t, r.Record(a))
+	require.NoError(t, r.Record(b))
+
+	docs, err := r.documents()
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, filepath.Join(dir, "2022", "05", "01"), filepath.Dir(docs[0].path))
+	assert.Equal(t, received, docs[0].received)
+	assert.Equal(t, received.Add(time.Millisecond), docs[1].received)
+
+	f, err := os.Open(docs[0].path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, testDoc("a"), string(raw))
+
+	bs, err := ioutil.ReadFile(docs[0].path + recordMetaExt)
+	require.NoError(t, err)
+	var meta recordMeta
+	require.NoError(t, json.Unmarshal(bs, &meta))
+	assert.Equal(t, recordMeta{
+		Filename: "a.xml",
+		Source:   "ftp://ftp.betradar.com:21/wns/a.xml",
+		Charset:  "UTF-8",
+		Received: received,
+		Size:     a.Size,
+		SHA256:   a.SHA256,
+	}, meta)
+}
+
+func TestFSRecorderPrune(t *testing.T) {
+	dir := t.TempDir()
+	r := &FSRecorder{Dir: dir}
+
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour, time.Minute} {
+		d := newData([]byte(testDoc("doc")), "", "")
+		d.Received = now.Add(-age)
+		require.NoError(t, r.Record(d))
+	}
+
+	r.MaxAge = 50 * time.Hour
+	require.NoError(t, r.Prune())
+	docs, err := r.documents()
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	assert.Equal(t, now.Add(-48*time.Hour), docs[0].received)
+
+	// Directory of the removed document is cleaned up.
+	_, err = os.Stat(filepath.Join(dir, now.Add(-72*time.Hour).Format("2006/01/02")))
+	assert.True(t, os.IsNotExist(err))
+
+	r.MaxSize = docs[1].size + docs[2].size
+	require.NoError(t, r.Prune())
+	docs, err = r.documents()
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, now.Add(-time.Hour), docs[0].received)
+}
+
+func TestFSRecorderCharset(t *testing.T) {
+	r := &FSRecorder{Dir: t.TempDir()}
+	d := newData([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><BetradarBetData/>"), "", "a.xml")
+	require.NoError(t, r.Record(d))
+
+	docs, err := r.documents()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	bs, err := ioutil.ReadFile(docs[0].path + recordMetaExt)
+	require.NoError(t, err)
+	var meta recordMeta
+	require.NoError(t, json.Unmarshal(bs, &meta))
+	assert.Equal(t, "ISO-8859-1", meta.Charset)
+}
+
+func TestFSRecorderBackgroundPrune(t *testing.T) {
+	r := &FSRecorder{Dir: t.TempDir(), MaxAge: time.Hour}
+
+	d := newData([]byte(testDoc("old")), "", "")
+	d.Received = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, r.Record(d))
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return !r.pruning
+	}, time.Second, time.Millisecond)
+
+	docs, err := r.documents()
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func TestFSRecorderPruneErr(t *testing.T) {
+	r := &FSRecorder{Dir: t.TempDir(), MaxAge: time.Hour}
+
+	// Metadata path of an expired document is a non-empty directory, so it
+	// can not be removed.
+	old := filepath.Join(r.Dir, "2000", "01", "01", "20000101T000000.000000000Z-abc.xml")
+	require.NoError(t, os.MkdirAll(filepath.Join(old+recordMetaExt, "blocker"), 0755))
+	require.NoError(t, ioutil.WriteFile(old, []byte(testDoc("old")), 0600))
+
+	pruned := func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return !r.pruning
+	}
+	require.NoError(t, r.Record(newData([]byte(testDoc("a")), "", "")))
+	assert.Eventually(t, pruned, time.Second, time.Millisecond)
+	assert.Error(t, r.PruneErr())
+
+	// Prune failure is not reported as failure of recording a document.
+	r.mu.Lock()
+	r.lastPrune = time.Time{}
+	r.mu.Unlock()
+	require.NoError(t, r.Record(newData([]byte(testDoc("b")), "", "")))
+	assert.Eventually(t, pruned, time.Second, time.Millisecond)
+
+	require.NoError(t, os.RemoveAll(old+recordMetaExt))
+	r.mu.Lock()
+	r.lastPrune = time.Time{}
+	r.mu.Unlock()
+	require.NoError(t, r.Record(newData([]byte(testDoc("c")), "", "")))
+	assert.Eventually(t, pruned, time.Second, time.Millisecond)
+	assert.NoError(t, r.PruneErr())
+}
+
+// testRecorder keeps recorded documents in memory.
+type testRecorder struct {
+	docs []Data
+}
+
+func (r *testRecorder) Record(d Data) error {
+	r.docs = append(r.docs, d)
+	return nil
+}
@@ -0,0 +1,214 @@
+package wns
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/advbet/wns/internal/sleep"
+)
+
+// ReplaySource is a Source replaying archived odds documents, e.g. recorded
+// by FSRecorder, timed the way they were originally received. Cursor is the
+// document path relative to archive directory.
+//
+// Document receive time is taken from FSRecorder metadata file or file name,
+// for other archives Timestamp.Created of the document is used. Replayed
+// documents keep their original Received time.
+type ReplaySource struct {
+	Dir string // Archive directory
+	// Speed is the replay speed factor, e.g. 10 replays an hour of
+	// documents in 6 minutes. Default is 1, real time. Positive infinity
+	// replays documents without delays.
+	Speed float64
+	// Start, if set, skips documents received before Start.
+	Start time.Time
+	// Step, if set, enables manual stepping. Instead of waiting for the
+	// original timing, each document is delivered after a value is
+	// received from Step.
+	Step <-chan struct{}
+}
+
+// replayDoc is a single archived document scheduled for replay.
+type replayDoc struct {
+	path     string // relative to archive directory
+	received time.Time
+	meta     recordMeta
+}
+
+// Stream implements Source interface. Stream is closed once all documents
+// are replayed.
+func (s *ReplaySource) Stream(ctx context.Context, cursor string) <-chan Data {
+	ch := make(chan Data)
+	go func() {
+		defer close(ch)
+		docs, err := s.documents()
+		if err != nil {
+			streamErr(ctx, ch, &Error{Op: "replay", Err: err})
+			return
+		}
+		docs = s.seek(docs, cursor)
+
+		speed := s.Speed
+		if speed <= 0 {
+			speed = 1
+		}
+		var wallStart time.Time
+		var archiveStart time.Time
+		for i, doc := range docs {
+			if i == 0 {
+				wallStart, archiveStart = time.Now(), doc.received
+			}
+			if s.Step != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.Step:
+				}
+			} else {
+				due := wallStart.Add(time.Duration(float64(doc.received.Sub(archiveStart)) / speed))
+				if !sleep.Until(ctx, due) {
+					return
+				}
+			}
+			d, err := s.read(doc)
+			if err != nil {
+				d = Data{Error: &Error{Kind: ErrTransport, Op: "replay", Filename: doc.path, Err: err}}
+			}
+			if !sendData(ctx, ch, d) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Ack implements Source interface.
+func (s *ReplaySource) Ack(d Data) error {
+	return d.Ack()
+}
+
+// seek skips documents received before Start and documents up to and
+// including `cursor`.
+func (s *ReplaySource) seek(docs []replayDoc, cursor string) []replayDoc {
+	if cursor != "" {
+		for i, doc := range docs {
+			if doc.path == cursor {
+				docs = docs[i+1:]
+				break
+			}
+		}
+	}
+	if !s.Start.IsZero() {
+		i := sort.Search(len(docs), func(i int) bool {
+			return !docs[i].received.Before(s.Start)
+		})
+		docs = docs[i:]
+	}
+	return docs
+}
+
+// documents lists archived documents in the order they were received.
+func (s *ReplaySource) documents() ([]replayDoc, error) {
+	var docs []replayDoc
+	err := filepath.Walk(s.Dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() || (!strings.HasSuffix(name, recordExt) && !strings.HasSuffix(name, recordExt+recordGzipExt)) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, p)
+		if err != nil {
+			return err
+		}
+		doc := replayDoc{path: filepath.ToSlash(rel)}
+		if bs, err := ioutil.ReadFile(p + recordMetaExt); err == nil {
+			if err := json.Unmarshal(bs, &doc.meta); err != nil {
+				return fmt.Errorf("reading %s metadata: %w", doc.path, err)
+			}
+		}
+		doc.received = doc.meta.Received
+		if doc.received.IsZero() {
+			doc.received, _ = parseRecordName(name)
+		}
+		if doc.received.IsZero() {
+			if doc.received, err = s.created(doc); err != nil {
+				return fmt.Errorf("reading %s creation time: %w", doc.path, err)
+			}
+		}
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].received.Equal(docs[j].received) {
+			return docs[i].path < docs[j].path
+		}
+		return docs[i].received.Before(docs[j].received)
+	})
+	return docs, nil
+}
+
+// created returns creation time of archived document from its Timestamp.
+func (s *ReplaySource) created(doc replayDoc) (time.Time, error) {
+	bs, err := s.load(doc.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var head struct {
+		Timestamp Timestamp `xml:"Timestamp"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(bs))
+	dec.CharsetReader = charsetReader
+	if err := dec.Decode(&head); err != nil {
+		return time.Time{}, err
+	}
+	return head.Timestamp.Time()
+}
+
+// read loads archived document into Data. Parse errors are reported in the
+// returned Data, not as an error.
+func (s *ReplaySource) read(doc replayDoc) (Data, error) {
+	bs, err := s.load(doc.path)
+	if err != nil {
+		return Data{}, err
+	}
+	source := doc.meta.Source
+	if source == "" {
+		source = "file://" + filepath.ToSlash(filepath.Join(s.Dir, doc.path))
+	}
+	d := newData(bs, source, doc.meta.Filename)
+	d.Received = doc.received
+	d.Cursor = doc.path
+	if err := d.decode(); err != nil {
+		d.Error = &Error{Kind: ErrDecode, Op: "replay", Filename: doc.path, Err: err}
+	}
+	return d, nil
+}
+
+// load reads archived document, decompressing it if needed.
+func (s *ReplaySource) load(path string) ([]byte, error) {
+	bs, err := ioutil.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(path)))
+	if err != nil || !strings.HasSuffix(path, recordGzipExt) {
+		return bs, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return ioutil.ReadAll(gz)
+}
@@ -0,0 +1,104 @@
+package wns
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testArchive records documents received a second apart starting at `start`
+// and adds a document without metadata, created at `created`.
+func testArchive(t *testing.T, start time.Time, created time.Time) string {
+	dir := t.TempDir()
+	r := &FSRecorder{Dir: dir, Compress: true}
+	for i := 0; i < 3; i++ {
+		d := newData([]byte(testDoc(fmt.Sprint(i))), "ftp://ftp.betradar.com:21/wns/", fmt.Sprintf("doc-%d.xml", i))
+		d.Received = start.Add(time.Duration(i) * time.Second)
+		require.NoError(t, r.Record(d))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "manual"), 0755))
+	doc := testDoc(created.Format(TimestampLayout))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "manual", "dropped.xml"), []byte(doc), 0644))
+	return dir
+}
+
+func TestReplaySource(t *testing.T) {
+	start := time.Date(2017, 3, 27, 9, 0, 0, 0, time.UTC)
+	dir := testArchive(t, start, start.Add(1500*time.Millisecond))
+
+	src := &ReplaySource{Dir: dir, Speed: math.Inf(1)}
+	var msgs []Data
+	for msg := range src.Stream(context.Background(), "") {
+		require.NoError(t, msg.Error)
+		msgs = append(msgs, msg)
+	}
+	require.Len(t, msgs, 4)
+	expected := []string{"0", "1", start.Add(1500 * time.Millisecond).Format(TimestampLayout), "2"}
+	for i, msg := range msgs {
+		assert.Equal(t, expected[i], msg.Data.Timestamp.Created)
+	}
+	assert.Equal(t, start.Add(time.Second), msgs[1].Received)
+	assert.Equal(t, "doc-1.xml", msgs[1].Filename)
+	assert.Equal(t, "manual/dropped.xml", msgs[2].Cursor)
+
+	// Seeking by cursor and by start time.
+	tests := []struct {
+		msg      string
+		cursor   string
+		start    time.Time
+		expected int
+	}{
+		{msg: "cursor", cursor: msgs[1].Cursor, expected: 2},
+		{msg: "start", start: start.Add(time.Second), expected: 3},
+		{msg: "start and cursor", start: start.Add(time.Second), cursor: msgs[2].Cursor, expected: 1},
+		{msg: "after end", start: start.Add(time.Hour), expected: 0},
+	}
+	for _, test := range tests {
+		src := &ReplaySource{Dir: dir, Speed: math.Inf(1), Start: test.start}
+		n := 0
+		for range src.Stream(context.Background(), test.cursor) {
+			n++
+		}
+		assert.Equal(t, test.expected, n, test.msg)
+	}
+}
+
+func TestReplaySourceTiming(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	dir := testArchive(t, start, start.Add(time.Second))
+
+	// Documents span 2 seconds, replayed in 200ms.
+	src, err := OpenSource("replay://" + filepath.ToSlash(dir) + "?speed=10")
+	require.NoError(t, err)
+	begin := time.Now()
+	n := 0
+	for range src.Stream(context.Background(), "") {
+		n++
+	}
+	assert.Equal(t, 4, n)
+	assert.True(t, time.Since(begin) >= 200*time.Millisecond, time.Since(begin))
+
+	step := make(chan struct{})
+	src = &ReplaySource{Dir: dir, Step: step}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := src.Stream(ctx, "")
+	select {
+	case <-stream:
+		t.Fatal("document delivered without a step")
+	case <-time.After(10 * time.Millisecond):
+	}
+	step <- struct{}{}
+	msg := <-stream
+	assert.Equal(t, "0", msg.Data.Timestamp.Created)
+	cancel()
+	for range stream {
+	}
+}
@@ -0,0 +1,113 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RetryPolicy describes how failed client operations are retried. Operations
+// are retried with exponentially growing delay as long as they fail with
+// retryable errors, see Retryable.
+//
+// Zero value RetryPolicy disables retries, each operation is attempted once.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one. Negative value removes the limit, then retrying is bound only
+	// by MaxElapsed.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff, if non zero, caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Multiplier is the factor delay grows by after each retry. Values
+	// less than 1 are treated as 2.
+	Multiplier float64
+	// Jitter is a fraction of delay, between 0 and 1, that is randomized
+	// so that clients failing at the same time do not retry in lockstep.
+	Jitter float64
+	// MaxElapsed, if non zero, stops retrying once the next attempt would
+	// start later than MaxElapsed after the first one.
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy is a retry policy suitable for most WNS clients. It rides
+// out short network and server outages, but gives up within a few minutes so
+// that streams can report the failure.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+	MaxElapsed:     5 * time.Minute,
+}
+
+// Do calls `op` until it succeeds, fails with an error that is not retryable or
+// policy limits are reached. Error of the last attempt is returned. Errors of
+// ErrNoData and ErrRateLimited kinds are returned without retrying, as waiting
+// for new documents or rate limit is up to the caller.
+//
+// If `ctx` is cancelled while waiting for the next attempt, context error is
+// returned.
+func (p RetryPolicy) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if !p.retry(err) || (p.MaxAttempts >= 0 && attempt >= p.MaxAttempts) {
+			return err
+		}
+		wait := p.backoff(attempt)
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retry reports whether operation failed with `err` should be retried.
+func (p RetryPolicy) retry(err error) bool {
+	return Retryable(err) && !errors.Is(err, ErrNoData) && !errors.Is(err, ErrRateLimited)
+}
+
+// backoff returns delay after failed attempt number `attempt`, starting with 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		wait *= multiplier
+		if p.MaxBackoff > 0 && wait >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait -= wait * p.Jitter * jitter()
+	}
+	return time.Duration(wait)
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitter returns a random number in [0, 1) range.
+func jitter() float64 {
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return jitterRand.Float64()
+}
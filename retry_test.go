@@ -0,0 +1,188 @@
+package wns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/advbet/wns/wnstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		300 * time.Millisecond,
+		900 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, wait := range expected {
+		assert.Equal(t, wait, p.backoff(i+1), "attempt %d", i+1)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		wait := p.backoff(2)
+		assert.True(t, wait > 150*time.Millisecond && wait <= 300*time.Millisecond, wait)
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	transient := &Error{Kind: ErrTransport, Op: "get", Err: errors.New("connection reset")}
+	permanent := &Error{Kind: ErrAuth, Op: "login", Err: errors.New("530 Login incorrect")}
+	fast := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := []struct {
+		msg      string
+		policy   RetryPolicy
+		errs     []error
+		expected error
+		attempts int
+	}{
+		{
+			msg:      "success",
+			policy:   fast,
+			errs:     []error{nil},
+			attempts: 1,
+		},
+		{
+			msg:      "recovered",
+			policy:   fast,
+			errs:     []error{transient, transient, nil},
+			attempts: 3,
+		},
+		{
+			msg:      "attempts exhausted",
+			policy:   fast,
+			errs:     []error{transient, transient, transient, nil},
+			expected: transient,
+			attempts: 3,
+		},
+		{
+			msg:      "permanent",
+			policy:   fast,
+			errs:     []error{permanent, nil},
+			expected: permanent,
+			attempts: 1,
+		},
+		{
+			msg:      "no data",
+			policy:   fast,
+			errs:     []error{&APIError{Type: ErrTypeNoNew}, nil},
+			expected: &APIError{Type: ErrTypeNoNew},
+			attempts: 1,
+		},
+		{
+			msg:      "zero value",
+			errs:     []error{transient, nil},
+			expected: transient,
+			attempts: 1,
+		},
+		{
+			msg:      "max elapsed",
+			policy:   RetryPolicy{MaxAttempts: -1, InitialBackoff: time.Hour, MaxElapsed: time.Minute},
+			errs:     []error{transient, nil},
+			expected: transient,
+			attempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		attempts := 0
+		err := test.policy.Do(context.Background(), func(ctx context.Context) error {
+			err := test.errs[attempts]
+			attempts++
+			return err
+		})
+		assert.Equal(t, test.expected, err, test.msg)
+		assert.Equal(t, test.attempts, attempts, test.msg)
+	}
+}
+
+func TestRetryPolicyDoCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	p := RetryPolicy{MaxAttempts: -1, InitialBackoff: time.Hour}
+	err := p.Do(ctx, func(ctx context.Context) error {
+		return &Error{Kind: ErrTransport, Op: "get", Err: errors.New("connection reset")}
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestHTTPPullRetry(t *testing.T) {
+	setTestRateLimit(t, time.Millisecond)
+
+	var mu sync.Mutex
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, `<BetradarBetData><Timestamp CreatedTime="doc"/></BetradarBetData>`)
+	}))
+	defer srv.Close()
+
+	c := HTTPPullClient{
+		URL:   srv.URL,
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}
+	doc, err := c.Get(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, "doc", doc.Timestamp.Created)
+}
+
+func TestStreamStopped(t *testing.T) {
+	setTestRateLimit(t, time.Millisecond)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	ftpSrv := wnstest.NewFTPServer(t)
+	u, err := url.Parse(ftpSrv.URL())
+	require.NoError(t, err)
+	u.User = url.UserPassword("user", "wrong")
+	ftpClient, err := NewFTPPullWithOptions(u.String(), FTPRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	require.NoError(t, err)
+
+	httpClient := HTTPPullClient{
+		URL:      srv.URL,
+		Interval: time.Millisecond,
+		Retry:    RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}
+
+	streams := map[string]<-chan Data{
+		"ftp":  ftpClient.Stream(context.Background(), "", time.Millisecond),
+		"http": httpClient.Stream(context.Background()),
+	}
+	for name, stream := range streams {
+		var msgs []Data
+		for msg := range stream {
+			msgs = append(msgs, msg)
+		}
+		require.Len(t, msgs, 1, name)
+		assert.Equal(t, StatusStopped, msgs[0].Status, name)
+		assert.True(t, errors.Is(msgs[0].Error, ErrAuth), name)
+	}
+	assert.Equal(t, 1, ftpSrv.Count("PASS"))
+}
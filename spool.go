@@ -0,0 +1,95 @@
+package wns
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/advbet/wns/internal/atomicfile"
+)
+
+// spool is a directory of received documents waiting to be acknowledged by
+// the stream consumer. Documents are named after their receive time, so
+// lexical order of file names is the order they were received in.
+type spool struct {
+	dir    string
+	source string
+}
+
+// put durably stores document in the spool. Returned Data removes the spooled
+// file when acknowledged.
+func (s spool) put(d Data) (Data, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return d, err
+	}
+	name := d.Received.UTC().Format(recordTimeLayout) + "-" + shortHash(d.SHA256) + recordExt
+	filename := filepath.Join(s.dir, name)
+	if err := atomicfile.WriteFile(filename, 0600, func(w io.Writer) error {
+		_, err := w.Write(d.Raw)
+		return err
+	}); err != nil {
+		return d, err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return d, err
+	}
+	d.ack = s.remover(filename)
+	return d, nil
+}
+
+// pending loads all unacknowledged documents from the spool, oldest first.
+func (s spool) pending() ([]Data, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	var docs []Data
+	for _, info := range infos {
+		name := info.Name()
+		received, ok := parseRecordName(name)
+		if info.IsDir() || !ok || !strings.HasSuffix(name, recordExt) {
+			continue
+		}
+		filename := filepath.Join(s.dir, name)
+		raw, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		d := newData(raw, s.source, "")
+		d.Received = received
+		d.Error = d.decode()
+		d.ack = s.remover(filename)
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+func (s spool) remover(filename string) func() error {
+	return func() error {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return syncDir(s.dir)
+	}
+}
+
+// syncDir flushes directory entries to disk, making file creation and removal
+// durable.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,44 @@
+package wns
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	s := spool{dir: t.TempDir(), source: "https://www.betradar.com/betradar/getXmlFeed.php"}
+
+	received := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, raw := range []string{testDoc("a"), "garbage", testDoc("c")} {
+		d := newData([]byte(raw), "", "")
+		d.Received = received.Add(time.Duration(i) * time.Second)
+		_, err := s.put(d)
+		require.NoError(t, err)
+	}
+
+	docs, err := s.pending()
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	assert.NoError(t, docs[0].Error)
+	assert.Equal(t, "a", docs[0].Data.Timestamp.Created)
+	assert.Equal(t, received, docs[0].Received)
+	assert.Equal(t, s.source, docs[0].Source)
+	assert.Error(t, docs[1].Error)
+	assert.Equal(t, []byte("garbage"), docs[1].Raw)
+	assert.Equal(t, "c", docs[2].Data.Timestamp.Created)
+
+	require.NoError(t, docs[0].Ack())
+	require.NoError(t, docs[1].Ack())
+	docs, err = s.pending()
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "c", docs[0].Data.Timestamp.Created)
+
+	infos, err := ioutil.ReadDir(s.dir)
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+}
@@ -0,0 +1,367 @@
+// Package builder constructs WNS odds documents for tests. Builders fill
+// sensible defaults for everything not set explicitly, so a valid document
+// takes a line or two:
+//
+//	doc := builder.Results().Draw(builder.NewDraw("20/80")).Build()
+//	raw := builder.Fixtures().Draw(builder.NewDraw("6/49").Bonus(1, builder.DrumSame)).XML()
+package builder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/advbet/wns"
+)
+
+// Document types.
+const (
+	TypeFixtures = "Fixtures"
+	TypeResults  = "Results"
+)
+
+// Bonus balls drums, see DrawBuilder.Bonus.
+const (
+	DrumSame     = "Same"     // Bonus balls are drawn from the remaining main balls
+	DrumSeparate = "Separate" // Bonus balls are drawn from a separate drum
+)
+
+// DrawDateLayout is the layout of Draw.DrawDate, see time.Parse.
+const DrawDateLayout = "2006-01-02 15:04:05"
+
+// Defaults used for values not set explicitly.
+const (
+	DefaultSportID      = 108
+	DefaultCategoryID   = 1103
+	DefaultCountry      = "Malta"
+	DefaultTournamentID = 49026
+)
+
+// lastID is the last draw ID assigned by NewDraw.
+var lastID int64 = 159000000
+
+// DocumentBuilder builds a single tournament odds document.
+type DocumentBuilder struct {
+	typ          string
+	created      time.Time
+	sportID      int
+	categoryID   int
+	country      string
+	tournamentID int
+	draws        []*DrawBuilder
+	bets         []wns.Bet
+}
+
+// Fixtures starts a document announcing upcoming draws. Draws of fixtures
+// documents carry no results. If no bets are added, the document offers
+// odd/even odds.
+func Fixtures() *DocumentBuilder {
+	return newDocument(TypeFixtures)
+}
+
+// Results starts a document with results of finished draws. Draws without
+// explicitly set balls get random results, see DrawBuilder.Seed.
+func Results() *DocumentBuilder {
+	return newDocument(TypeResults)
+}
+
+func newDocument(typ string) *DocumentBuilder {
+	return &DocumentBuilder{
+		typ:          typ,
+		sportID:      DefaultSportID,
+		categoryID:   DefaultCategoryID,
+		country:      DefaultCountry,
+		tournamentID: DefaultTournamentID,
+	}
+}
+
+// Created sets document creation time, the default is current time.
+func (b *DocumentBuilder) Created(t time.Time) *DocumentBuilder {
+	b.created = t
+	return b
+}
+
+// Sport sets Betradar sport ID.
+func (b *DocumentBuilder) Sport(id int) *DocumentBuilder {
+	b.sportID = id
+	return b
+}
+
+// Category sets Betradar category ID and its country name.
+func (b *DocumentBuilder) Category(id int, country string) *DocumentBuilder {
+	b.categoryID = id
+	b.country = country
+	return b
+}
+
+// Tournament sets Betradar tournament ID identifying the game.
+func (b *DocumentBuilder) Tournament(id int) *DocumentBuilder {
+	b.tournamentID = id
+	return b
+}
+
+// Draw adds draws to the document.
+func (b *DocumentBuilder) Draw(draws ...*DrawBuilder) *DocumentBuilder {
+	b.draws = append(b.draws, draws...)
+	return b
+}
+
+// Bet adds odds of a single bet type.
+func (b *DocumentBuilder) Bet(oddsType int, odds ...wns.Odds) *DocumentBuilder {
+	b.bets = append(b.bets, wns.Bet{OddsType: oddsType, Odds: odds})
+	return b
+}
+
+// Build returns the document as parsed by WNS clients.
+func (b *DocumentBuilder) Build() wns.BetradarBetData {
+	created := b.created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	tournament := wns.Tournament{ID: b.tournamentID}
+	for _, d := range b.draws {
+		draw := d.Build()
+		if b.typ != TypeResults {
+			draw.Result = wns.Result{}
+		}
+		tournament.Draws = append(tournament.Draws, draw)
+	}
+	tournament.Bets = append(tournament.Bets, b.bets...)
+	if b.typ == TypeFixtures && len(tournament.Bets) == 0 {
+		tournament.Bets = []wns.Bet{{
+			OddsType: 465,
+			Odds: []wns.Odds{
+				{ID: 9, Outcome: "Odd", Odds: "1.80"},
+				{ID: 10, Outcome: "Even", Odds: "1.80"},
+			},
+		}}
+	}
+	return wns.BetradarBetData{
+		XMLName: xml.Name{Local: "BetradarBetData"},
+		Type:    b.typ,
+		Timestamp: wns.Timestamp{
+			Created: created.UTC().Format(wns.TimestampLayout),
+			TZ:      "UTC",
+		},
+		Sports: []wns.Sport{{
+			ID: b.sportID,
+			Category: wns.Category{
+				ID:         b.categoryID,
+				Country:    b.country,
+				Tournament: tournament,
+			},
+		}},
+	}
+}
+
+// XML returns the document rendered as WNS delivers it.
+func (b *DocumentBuilder) XML() []byte {
+	doc := b.Build()
+	raw, err := xml.MarshalIndent(&doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(xml.Header), raw...)
+}
+
+// DrawBuilder builds a single draw of a game.
+type DrawBuilder struct {
+	draw  wns.Draw
+	balls int
+	max   int
+	main  []int
+	bonus []int
+	seed  int64
+	at    time.Time
+}
+
+// NewDraw starts a draw of `gameType` game, e.g. "20/80" for 20 balls drawn
+// out of 80. Draw gets a unique ID and the default name for its game type.
+// NewDraw panics if game type is malformed.
+func NewDraw(gameType string) *DrawBuilder {
+	balls, max, err := ParseGameType(gameType)
+	if err != nil {
+		panic(err)
+	}
+	id := atomic.AddInt64(&lastID, 1)
+	name := "Lotto " + gameType
+	if balls == 20 {
+		name = "Keno " + gameType
+	}
+	return &DrawBuilder{
+		draw: wns.Draw{
+			ID:        int(id),
+			DisplayID: int(id % 1000),
+			Type:      "Rng",
+			TimeType:  "Interval",
+			GameType:  gameType,
+			Name:      name,
+		},
+		balls: balls,
+		max:   max,
+		seed:  id,
+	}
+}
+
+// ParseGameType parses game type "N/M" into the number of balls drawn and the
+// highest ball number.
+func ParseGameType(gameType string) (balls, max int, err error) {
+	i := strings.IndexByte(gameType, '/')
+	if i != -1 {
+		balls, err = strconv.Atoi(gameType[:i])
+		if err == nil {
+			max, err = strconv.Atoi(gameType[i+1:])
+		}
+	}
+	if i == -1 || err != nil || balls < 1 || balls > max {
+		return 0, 0, fmt.Errorf("invalid game type %q", gameType)
+	}
+	return balls, max, nil
+}
+
+// ID sets Betradar draw ID.
+func (b *DrawBuilder) ID(id int) *DrawBuilder {
+	b.draw.ID = id
+	return b
+}
+
+// DisplayID sets draw number displayed to players.
+func (b *DrawBuilder) DisplayID(id int) *DrawBuilder {
+	b.draw.DisplayID = id
+	return b
+}
+
+// Name sets game name.
+func (b *DrawBuilder) Name(name string) *DrawBuilder {
+	b.draw.Name = name
+	return b
+}
+
+// Type sets draw type, "Rng" (default) or "Drum".
+func (b *DrawBuilder) Type(typ string) *DrawBuilder {
+	b.draw.Type = typ
+	return b
+}
+
+// TimeType sets draw schedule type, "Interval" (default) or "Fixed".
+func (b *DrawBuilder) TimeType(typ string) *DrawBuilder {
+	b.draw.TimeType = typ
+	return b
+}
+
+// At sets draw time, the default is current time truncated to a minute.
+func (b *DrawBuilder) At(t time.Time) *DrawBuilder {
+	b.at = t
+	return b
+}
+
+// Canceled marks draw as canceled.
+func (b *DrawBuilder) Canceled() *DrawBuilder {
+	b.draw.Canceled = true
+	return b
+}
+
+// Bonus adds `n` bonus balls drawn from `drum`, DrumSame or DrumSeparate. A
+// separate drum holds as many balls as the main one, use BonusRange to
+// change it.
+func (b *DrawBuilder) Bonus(n int, drum string) *DrawBuilder {
+	b.draw.BonusBalls = n
+	b.draw.BonusBallsDrum = drum
+	b.draw.BonusBallsRange = fmt.Sprintf("1-%d", b.max)
+	return b
+}
+
+// BonusRange sets the highest bonus ball number of a separate drum.
+func (b *DrawBuilder) BonusRange(max int) *DrawBuilder {
+	b.draw.BonusBallsRange = fmt.Sprintf("1-%d", max)
+	return b
+}
+
+// Balls sets drawn main balls, they are reported in given order. Balls are
+// not validated, so inconsistent results can be built on purpose.
+func (b *DrawBuilder) Balls(balls ...int) *DrawBuilder {
+	b.main = balls
+	return b
+}
+
+// BonusBalls sets drawn bonus balls, they are not validated either.
+func (b *DrawBuilder) BonusBalls(balls ...int) *DrawBuilder {
+	b.bonus = balls
+	return b
+}
+
+// Seed sets the seed of random results generated for balls not set
+// explicitly, the default seed is the draw ID as assigned by NewDraw.
+func (b *DrawBuilder) Seed(seed int64) *DrawBuilder {
+	b.seed = seed
+	return b
+}
+
+// Build returns the draw with its result. Unless set explicitly, the result
+// has distinct main balls in numerical order followed by bonus balls, which
+// are distinct from the main balls if drawn from the same drum.
+func (b *DrawBuilder) Build() wns.Draw {
+	draw := b.draw
+	at := b.at
+	if at.IsZero() {
+		at = time.Now().Truncate(time.Minute)
+	}
+	draw.DrawDate = at.UTC().Format(DrawDateLayout)
+
+	main, bonus := b.main, b.bonus
+	rng := rand.New(rand.NewSource(b.seed))
+	if main == nil {
+		main = b.draws(rng)
+	}
+	if bonus == nil && draw.BonusBalls > 0 {
+		bonus = b.drawBonus(rng, main)
+	}
+	var scores []wns.Score
+	for i, ball := range main {
+		scores = append(scores, wns.Score{Type: fmt.Sprintf("draw_%d", i+1), Value: strconv.Itoa(ball)})
+	}
+	for i, ball := range bonus {
+		scores = append(scores, wns.Score{Type: fmt.Sprintf("draw_b%d", i+1), Value: strconv.Itoa(ball)})
+	}
+	draw.Result = wns.Result{ScoreInfo: scores}
+	return draw
+}
+
+// draws returns sorted main balls drawn at random.
+func (b *DrawBuilder) draws(rng *rand.Rand) []int {
+	balls := make([]int, b.balls)
+	for i, n := range rng.Perm(b.max)[:b.balls] {
+		balls[i] = n + 1
+	}
+	sort.Ints(balls)
+	return balls
+}
+
+// drawBonus returns bonus balls drawn at random after `main` balls.
+func (b *DrawBuilder) drawBonus(rng *rand.Rand, main []int) []int {
+	max := b.max
+	if _, err := fmt.Sscanf(b.draw.BonusBallsRange, "1-%d", &max); err != nil {
+		max = b.max
+	}
+	drawn := make(map[int]bool)
+	if b.draw.BonusBallsDrum == DrumSame {
+		for _, ball := range main {
+			drawn[ball] = true
+		}
+	}
+	var balls []int
+	for _, n := range rng.Perm(max) {
+		if len(balls) == b.draw.BonusBalls {
+			break
+		}
+		if !drawn[n+1] {
+			balls = append(balls, n+1)
+		}
+	}
+	return balls
+}
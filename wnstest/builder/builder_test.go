@@ -0,0 +1,155 @@
+package builder_test
+
+import (
+	"encoding/xml"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/wnstest/builder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResults(t *testing.T) {
+	tests := []struct {
+		msg      string
+		draw     *builder.DrawBuilder
+		balls    int
+		max      int
+		bonus    int
+		bonusMax int
+		sameDrum bool
+	}{
+		{
+			msg:   "keno",
+			draw:  builder.NewDraw("20/80"),
+			balls: 20,
+			max:   80,
+		},
+		{
+			msg:      "lotto with bonus ball",
+			draw:     builder.NewDraw("6/49").Bonus(1, builder.DrumSame),
+			balls:    6,
+			max:      49,
+			bonus:    1,
+			bonusMax: 49,
+			sameDrum: true,
+		},
+		{
+			msg:      "separate bonus drum",
+			draw:     builder.NewDraw("5/50").Bonus(2, builder.DrumSeparate).BonusRange(10),
+			balls:    5,
+			max:      50,
+			bonus:    2,
+			bonusMax: 10,
+		},
+		{
+			msg:      "all balls drawn",
+			draw:     builder.NewDraw("5/6").Bonus(1, builder.DrumSame),
+			balls:    5,
+			max:      6,
+			bonus:    1,
+			bonusMax: 6,
+			sameDrum: true,
+		},
+	}
+
+	for _, test := range tests {
+		doc := builder.Results().Draw(test.draw).Build()
+		require.Len(t, doc.Sports, 1, test.msg)
+		draws := doc.Sports[0].Category.Tournament.Draws
+		require.Len(t, draws, 1, test.msg)
+		scores := draws[0].Result.ScoreInfo
+		require.Len(t, scores, test.balls+test.bonus, test.msg)
+
+		seen := make(map[int]bool)
+		prev := 0
+		for i, score := range scores {
+			ball, err := strconv.Atoi(score.Value)
+			require.NoError(t, err, test.msg)
+			if i < test.balls {
+				assert.Equal(t, "draw_"+strconv.Itoa(i+1), score.Type, test.msg)
+				assert.True(t, ball > prev && ball <= test.max, "%s: ball %d", test.msg, ball)
+				prev = ball
+				seen[ball] = true
+				continue
+			}
+			assert.Equal(t, "draw_b"+strconv.Itoa(i-test.balls+1), score.Type, test.msg)
+			assert.True(t, ball >= 1 && ball <= test.bonusMax, "%s: bonus ball %d", test.msg, ball)
+			if test.sameDrum {
+				assert.False(t, seen[ball], "%s: bonus ball %d", test.msg, ball)
+			}
+		}
+	}
+}
+
+func TestResultsDeterministic(t *testing.T) {
+	a := builder.NewDraw("20/80").Seed(42).Build()
+	b := builder.NewDraw("20/80").Seed(42).Build()
+	assert.Equal(t, a.Result, b.Result)
+	assert.NotEqual(t, a.ID, b.ID)
+
+	d := builder.NewDraw("6/49").Balls(1, 2, 3, 4, 5, 6).Build()
+	require.Len(t, d.Result.ScoreInfo, 6)
+	assert.Equal(t, wns.Score{Type: "draw_6", Value: "6"}, d.Result.ScoreInfo[5])
+}
+
+func TestFixtures(t *testing.T) {
+	doc := builder.Fixtures().Draw(builder.NewDraw("20/80"), builder.NewDraw("20/80")).Build()
+	assert.Equal(t, builder.TypeFixtures, doc.Type)
+	tournament := doc.Sports[0].Category.Tournament
+	require.Len(t, tournament.Draws, 2)
+	for _, draw := range tournament.Draws {
+		assert.Empty(t, draw.Result.ScoreInfo)
+	}
+	assert.NotEmpty(t, tournament.Bets)
+}
+
+func TestXML(t *testing.T) {
+	created := time.Date(2017, 3, 27, 9, 19, 2, 0, time.UTC)
+	b := builder.Results().
+		Created(created).
+		Category(1061, "Canada").
+		Tournament(47111).
+		Draw(builder.NewDraw("20/70").ID(159768499).DisplayID(30).Type("Drum").TimeType("Fixed").At(time.Date(2017, 3, 27, 9, 15, 0, 0, time.UTC))).
+		Bet(517, wns.Odds{Outcome: "Hit", SpecialBetValue: "1", Odds: "3.20"})
+
+	expected := b.Build()
+	assert.Equal(t, "Mon 2017-03-27 09:19:02", expected.Timestamp.Created)
+	draw := expected.Sports[0].Category.Tournament.Draws[0]
+	assert.Equal(t, "2017-03-27 09:15:00", draw.DrawDate)
+	assert.Equal(t, "Keno 20/70", draw.Name)
+
+	var doc wns.BetradarBetData
+	require.NoError(t, xml.Unmarshal(b.XML(), &doc))
+	assert.Equal(t, expected, doc)
+}
+
+func TestParseGameType(t *testing.T) {
+	tests := []struct {
+		gameType string
+		balls    int
+		max      int
+		err      bool
+	}{
+		{gameType: "20/80", balls: 20, max: 80},
+		{gameType: "6/49", balls: 6, max: 49},
+		{gameType: "6", err: true},
+		{gameType: "7/6", err: true},
+		{gameType: "0/6", err: true},
+		{gameType: "a/b", err: true},
+	}
+	for _, test := range tests {
+		balls, max, err := builder.ParseGameType(test.gameType)
+		if test.err {
+			assert.Error(t, err, test.gameType)
+			continue
+		}
+		require.NoError(t, err, test.gameType)
+		assert.Equal(t, test.balls, balls, test.gameType)
+		assert.Equal(t, test.max, max, test.gameType)
+	}
+}
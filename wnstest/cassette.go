@@ -0,0 +1,238 @@
+package wnstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/advbet/wns/internal/atomicfile"
+)
+
+// Redacted replaces values of secret query parameters in cassettes.
+const Redacted = "REDACTED"
+
+// redactedParams lists query parameters never written to cassettes.
+var redactedParams = []string{"key"}
+
+// Cassette is a recorded sequence of HTTP exchanges, it is stored as a JSON
+// file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded HTTP exchange. Either Response or Error is
+// set.
+type Interaction struct {
+	Request  RecordedRequest   `json:"request"`
+	Response *RecordedResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"` // Transport error
+}
+
+// RecordedRequest identifies a recorded request. Request headers and body are
+// not recorded, WNS requests are fully described by their URL.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"` // URL with secret query parameters redacted
+}
+
+// RecordedResponse is a recorded response. Body is stored as text if it is
+// valid UTF-8, otherwise BodyBytes holds it base64 encoded.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	BodyBytes  []byte      `json:"body_bytes,omitempty"`
+}
+
+// LoadCassette reads cassette file.
+func LoadCassette(filename string) (*Cassette, error) {
+	bs, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", filename, err)
+	}
+	return &c, nil
+}
+
+// Save writes cassette file atomically.
+func (c *Cassette) Save(filename string) error {
+	bs, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filename, 0600, func(w io.Writer) error {
+		_, err := w.Write(bs)
+		return err
+	})
+}
+
+// redactURL returns `u` with secret query parameters redacted.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	q := redacted.Query()
+	changed := false
+	for _, param := range redactedParams {
+		if _, ok := q[param]; ok {
+			q.Set(param, Redacted)
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = q.Encode()
+	}
+	redacted.User = nil
+	return redacted.String()
+}
+
+// redactSecrets returns `msg` with values of secret query parameters of `u`
+// redacted.
+func redactSecrets(msg string, u *url.URL) string {
+	q := u.Query()
+	for _, param := range redactedParams {
+		for _, value := range q[param] {
+			if value != "" {
+				msg = strings.ReplaceAll(msg, value, Redacted)
+				msg = strings.ReplaceAll(msg, url.QueryEscape(value), Redacted)
+			}
+		}
+	}
+	return msg
+}
+
+// RecordingTransport is an http.RoundTripper recording all exchanges to a
+// cassette file, e.g. to capture real WNS responses for regression tests.
+// Cassette file is rewritten after each exchange. Use it as transport of
+// wns.HTTPPullClient.HTTPClient:
+//
+//	c.HTTPClient.Transport = &wnstest.RecordingTransport{Filename: "testdata/pull.json"}
+type RecordingTransport struct {
+	Filename  string            // Cassette file
+	Transport http.RoundTripper // Transport doing requests, default is http.DefaultTransport
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	interaction := Interaction{
+		Request: RecordedRequest{Method: req.Method, URL: redactURL(req.URL)},
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		interaction.Error = redactSecrets(err.Error(), req.URL)
+		if saveErr := t.record(interaction); saveErr != nil {
+			return nil, saveErr
+		}
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	recorded := &RecordedResponse{StatusCode: resp.StatusCode, Header: resp.Header}
+	if utf8.Valid(body) {
+		recorded.Body = string(body)
+	} else {
+		recorded.BodyBytes = body
+	}
+	interaction.Response = recorded
+	if err := t.record(interaction); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *RecordingTransport) record(interaction Interaction) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	return t.cassette.Save(t.Filename)
+}
+
+// ErrCassetteMismatch is returned by ReplayTransport for requests that do not
+// match the next recorded request.
+var ErrCassetteMismatch = errors.New("request does not match cassette")
+
+// ReplayTransport is an http.RoundTripper serving exchanges recorded by
+// RecordingTransport. Requests must be made in the recorded order, each
+// request has to match the method and the redacted URL of the next recorded
+// request. Recorded transport errors are replayed as errors with the same
+// message.
+type ReplayTransport struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	next     int
+}
+
+// NewReplayTransport loads cassette file for replaying.
+func NewReplayTransport(filename string) (*ReplayTransport, error) {
+	c, err := LoadCassette(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayTransport{cassette: c}, nil
+}
+
+// Remaining returns the number of recorded exchanges not replayed yet.
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.cassette.Interactions) - t.next
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	got := RecordedRequest{Method: req.Method, URL: redactURL(req.URL)}
+	if t.next >= len(t.cassette.Interactions) {
+		return nil, fmt.Errorf("%w: %s %s, cassette exhausted", ErrCassetteMismatch, got.Method, got.URL)
+	}
+	interaction := t.cassette.Interactions[t.next]
+	if got != interaction.Request {
+		return nil, fmt.Errorf("%w: %s %s, expected %s %s", ErrCassetteMismatch, got.Method, got.URL, interaction.Request.Method, interaction.Request.URL)
+	}
+	t.next++
+	if interaction.Response == nil {
+		return nil, errors.New(interaction.Error)
+	}
+
+	recorded := interaction.Response
+	body := recorded.BodyBytes
+	if body == nil {
+		body = []byte(recorded.Body)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
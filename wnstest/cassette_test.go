@@ -0,0 +1,114 @@
+package wnstest_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/wnstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pull.json")
+	latin1 := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><BetradarBetData DocumentType=\"Results\"><Timestamp CreatedTime=\"K\xf8benhavn\" TimeZone=\"UTC\"/></BetradarBetData>")
+
+	srv := wnstest.NewServer("bookie", "secret")
+	srv.RateLimit = 0
+	srv.Enqueue(testDoc("a"), latin1)
+	srv.Inject(wnstest.Fault{Drop: true})
+
+	// exchange makes the same sequence of requests while recording and
+	// replaying.
+	exchange := func(c *wns.HTTPPullClient) []error {
+		ctx := context.Background()
+		_, dropErr := c.Get(ctx, true)
+		doc, err := c.Get(ctx, true)
+		require.NoError(t, err)
+		assert.Equal(t, "a", doc.Timestamp.Created)
+		doc, err = c.Get(ctx, true)
+		require.NoError(t, err)
+		assert.Equal(t, "København", doc.Timestamp.Created)
+		_, noDataErr := c.Get(ctx, true)
+		return []error{dropErr, noDataErr}
+	}
+
+	recorder := &wnstest.RecordingTransport{
+		Filename:  filename,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	c := &wns.HTTPPullClient{
+		Username:   "bookie",
+		Key:        "secret",
+		URL:        srv.URL,
+		HTTPClient: http.Client{Transport: recorder},
+	}
+	recorded := exchange(c)
+	assert.True(t, errors.Is(recorded[0], wns.ErrTransport), recorded[0])
+	assert.True(t, errors.Is(recorded[1], wns.ErrNoData), recorded[1])
+	srv.Close()
+
+	raw, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+	assert.Contains(t, string(raw), "key="+wnstest.Redacted)
+
+	replayer, err := wnstest.NewReplayTransport(filename)
+	require.NoError(t, err)
+	assert.Equal(t, 4, replayer.Remaining())
+	c.HTTPClient = http.Client{Transport: replayer}
+	replayed := exchange(c)
+	assert.True(t, errors.Is(replayed[0], wns.ErrTransport), replayed[0])
+	assert.True(t, errors.Is(replayed[1], wns.ErrNoData), replayed[1])
+	assert.Equal(t, 0, replayer.Remaining())
+
+	_, err = c.Get(context.Background(), true)
+	assert.True(t, errors.Is(err, wnstest.ErrCassetteMismatch), err)
+}
+
+func TestCassetteMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pull.json")
+	cassette := &wnstest.Cassette{Interactions: []wnstest.Interaction{{
+		Request: wnstest.RecordedRequest{
+			Method: http.MethodGet,
+			URL:    "http://wns.test/getXmlFeed.php?bookmakerName=bookie&deleteAfterTransfer=yes&key=REDACTED&xmlFeedName=FileGet",
+		},
+		Response: &wnstest.RecordedResponse{StatusCode: http.StatusOK, Body: wnstest.NoFilesBody},
+	}}}
+	require.NoError(t, cassette.Save(filename))
+
+	tests := []struct {
+		msg string
+		url string
+		err error
+	}{
+		{
+			msg: "other feed",
+			url: "http://wns.test/other.php",
+			err: wnstest.ErrCassetteMismatch,
+		},
+		{
+			msg: "any key matches",
+			url: "http://wns.test/getXmlFeed.php",
+			err: wns.ErrNoData,
+		},
+	}
+	for _, test := range tests {
+		replayer, err := wnstest.NewReplayTransport(filename)
+		require.NoError(t, err)
+		c := wns.HTTPPullClient{
+			Username:   "bookie",
+			Key:        "other",
+			URL:        test.url,
+			HTTPClient: http.Client{Transport: replayer},
+		}
+		_, err = c.Get(context.Background(), true)
+		assert.True(t, errors.Is(err, test.err), "%s: %v", test.msg, err)
+	}
+}
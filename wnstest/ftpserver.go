@@ -0,0 +1,489 @@
+package wnstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FTPServer is a minimal FTP server with in-memory filesystem, a stand-in for
+// WNS FTP-pull server. It implements login, passive mode (EPSV and PASV),
+// LIST, MLSD, MDTM, RETR and DELE commands and optionally explicit TLS. All
+// odds documents are stored in a single directory, "/wns" by default.
+//
+// Files, latency and connection drops can be scripted while clients are
+// connected.
+type FTPServer struct {
+	ln  net.Listener
+	tls *tls.Config
+
+	mu       sync.Mutex
+	user     string
+	pass     string
+	dir      string
+	files    map[string]ftpFile
+	seen     map[string]int
+	drops    map[string]int
+	latency  time.Duration
+	transfer time.Duration
+	mlsd     bool
+	mdtm     bool
+	logins   int // Number of logged in sessions
+	maxLogin int // Limit of concurrently logged in sessions, 0 if unlimited
+}
+
+type ftpFile struct {
+	data  []byte
+	mtime time.Time
+}
+
+// NewFTPServer starts FTP server listening on a random local port. Server is
+// closed when the test finishes. It accepts "user" and "pass" credentials.
+func NewFTPServer(tb testing.TB) *FTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := &FTPServer{
+		ln:    ln,
+		user:  "user",
+		pass:  "pass",
+		dir:   "/wns",
+		files: make(map[string]ftpFile),
+		seen:  make(map[string]int),
+		drops: make(map[string]int),
+	}
+	go s.serve()
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// NewFTPSServer starts FTP server requiring explicit TLS, clients have to
+// upgrade control connection with AUTH TLS before logging in. Returned pool
+// contains self-signed certificate of the server.
+func NewFTPSServer(tb testing.TB) (*FTPServer, *x509.CertPool) {
+	cert, pool := certificate(tb)
+	s := NewFTPServer(tb)
+	s.tls = &tls.Config{Certificates: []tls.Certificate{cert}}
+	return s, pool
+}
+
+// certificate generates self-signed certificate valid for 127.0.0.1.
+func certificate(tb testing.TB) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wns test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// Close stops accepting new connections.
+func (s *FTPServer) Close() {
+	s.ln.Close()
+}
+
+// Addr returns server address.
+func (s *FTPServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// URL returns FTP-pull base URL pointing to the server.
+func (s *FTPServer) URL() string {
+	scheme := "ftp"
+	if s.tls != nil {
+		scheme = "ftps"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%s://%s:%s@%s%s", scheme, s.user, s.pass, s.ln.Addr(), s.dir)
+}
+
+// SetCredentials changes user name and password accepted by the server.
+func (s *FTPServer) SetCredentials(user, pass string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user, s.pass = user, pass
+}
+
+// SetFeatures enables MLSD listings and MDTM command. By default server only
+// supports LIST with minute precision timestamps.
+func (s *FTPServer) SetFeatures(mlsd, mdtm bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mlsd, s.mdtm = mlsd, mdtm
+}
+
+// SetLatency sets delay before each control connection reply.
+func (s *FTPServer) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetTransferDelay sets delay before sending file contents over data
+// connection.
+func (s *FTPServer) SetTransferDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transfer = d
+}
+
+// SetMaxLogins limits number of concurrently logged in sessions, like FTP
+// servers often do per account. Logins above the limit are rejected with 421
+// reply and the connection is closed. Zero removes the limit.
+func (s *FTPServer) SetMaxLogins(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxLogin = n
+}
+
+// DropNext makes the server close control connection without a reply the next
+// `n` times FTP command `cmd` (e.g. "RETR") is received.
+func (s *FTPServer) DropNext(cmd string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops[strings.ToUpper(cmd)] += n
+}
+
+// AddFile adds or replaces odds document file.
+func (s *FTPServer) AddFile(name string, data []byte, mtime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = ftpFile{data: data, mtime: mtime}
+}
+
+// RemoveFile removes odds document file, it reports whether file existed.
+func (s *FTPServer) RemoveFile(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[name]; !ok {
+		return false
+	}
+	delete(s.files, name)
+	return true
+}
+
+// HasFile reports whether odds document file exists.
+func (s *FTPServer) HasFile(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[name]
+	return ok
+}
+
+// Files returns sorted names of odds document files.
+func (s *FTPServer) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Count returns how many times FTP command was received.
+func (s *FTPServer) Count(cmd string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seen[strings.ToUpper(cmd)]
+}
+
+func (s *FTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.Serve(conn)
+	}
+}
+
+// Serve runs FTP session over `conn`, e.g. one end of net.Pipe, and closes it
+// when session ends. Passive data connections are still opened on local TCP
+// ports.
+func (s *FTPServer) Serve(conn net.Conn) {
+	defer conn.Close()
+	tc := textproto.NewConn(conn)
+	reply := func(format string, args ...interface{}) {
+		s.mu.Lock()
+		latency := s.latency
+		s.mu.Unlock()
+		time.Sleep(latency)
+		_ = tc.PrintfLine(format, args...)
+	}
+
+	secure := false
+	protected := false
+	var pasv net.Listener
+	defer func() {
+		if pasv != nil {
+			pasv.Close()
+		}
+	}()
+	// transfer accepts data connection opened after EPSV/PASV and hands
+	// it over to fn.
+	transfer := func(fn func(net.Conn)) {
+		if pasv == nil {
+			reply("425 Use EPSV or PASV first")
+			return
+		}
+		data, err := pasv.Accept()
+		pasv.Close()
+		pasv = nil
+		if err != nil {
+			reply("425 Can't open data connection")
+			return
+		}
+		if protected {
+			data = tls.Server(data, s.tls)
+		}
+		reply("150 Opening data connection")
+		fn(data)
+		data.Close()
+		reply("226 Transfer complete")
+	}
+
+	reply("220 WNS test server ready")
+	loggedIn, userOK := false, false
+	defer func() {
+		if loggedIn {
+			s.mu.Lock()
+			s.logins--
+			s.mu.Unlock()
+		}
+	}()
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		cmd = strings.ToUpper(cmd)
+		s.mu.Lock()
+		s.seen[cmd]++
+		drop := s.drops[cmd] > 0
+		if drop {
+			s.drops[cmd]--
+		}
+		user, pass, dir := s.user, s.pass, s.dir
+		mlsd, mdtm := s.mlsd, s.mdtm
+		s.mu.Unlock()
+		if drop {
+			return
+		}
+
+		switch cmd {
+		case "FEAT":
+			reply("211-Features:")
+			reply(" EPSV")
+			reply(" PASV")
+			if mlsd {
+				reply(" MLST type*;size*;modify*;")
+			}
+			if mdtm {
+				reply(" MDTM")
+			}
+			if s.tls != nil {
+				reply(" AUTH TLS")
+				reply(" PBSZ")
+				reply(" PROT")
+			}
+			reply("211 End")
+			continue
+		case "AUTH":
+			if s.tls == nil || secure {
+				reply("502 Command not implemented")
+				continue
+			}
+			reply("234 Proceed with negotiation")
+			conn = tls.Server(conn, s.tls)
+			tc = textproto.NewConn(conn)
+			secure = true
+			continue
+		case "USER":
+			if s.tls != nil && !secure {
+				reply("530 TLS required")
+				continue
+			}
+			userOK = arg == user
+			reply("331 Password required")
+			continue
+		case "PASS":
+			if !userOK || arg != pass {
+				reply("530 Login incorrect")
+				continue
+			}
+			s.mu.Lock()
+			full := !loggedIn && s.maxLogin > 0 && s.logins >= s.maxLogin
+			if !full && !loggedIn {
+				s.logins++
+			}
+			s.mu.Unlock()
+			if full {
+				reply("421 Too many users logged in")
+				return
+			}
+			loggedIn = true
+			reply("230 Logged in")
+			continue
+		case "QUIT":
+			reply("221 Bye")
+			return
+		}
+		if !loggedIn {
+			reply("530 Not logged in")
+			continue
+		}
+
+		switch cmd {
+		case "TYPE", "OPTS", "PBSZ":
+			reply("200 OK")
+		case "PROT":
+			if !secure {
+				reply("503 AUTH TLS first")
+				continue
+			}
+			protected = arg == "P"
+			reply("200 OK")
+		case "NOOP":
+			reply("200 OK")
+		case "EPSV", "PASV":
+			if pasv != nil {
+				pasv.Close()
+			}
+			pasv, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 Can't open passive connection")
+				continue
+			}
+			port := pasv.Addr().(*net.TCPAddr).Port
+			if cmd == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port/256, port%256)
+			}
+		case "LIST", "MLSD":
+			if path.Clean(arg) != dir || (cmd == "MLSD" && !mlsd) {
+				reply("550 No such directory")
+				continue
+			}
+			lines := s.listLines(cmd == "MLSD")
+			transfer(func(data net.Conn) {
+				for _, l := range lines {
+					fmt.Fprintf(data, "%s\r\n", l)
+				}
+			})
+		case "RETR":
+			f, ok := s.lookup(arg)
+			if !ok {
+				reply("550 No such file")
+				continue
+			}
+			transfer(func(data net.Conn) {
+				s.mu.Lock()
+				delay := s.transfer
+				s.mu.Unlock()
+				time.Sleep(delay)
+				_, _ = data.Write(f.data)
+			})
+		case "MDTM":
+			f, ok := s.lookup(arg)
+			if !ok || !mdtm {
+				reply("550 No such file")
+				continue
+			}
+			reply("213 %s", f.mtime.UTC().Format("20060102150405.000"))
+		case "DELE":
+			if !s.remove(arg) {
+				reply("550 No such file")
+				continue
+			}
+			reply("250 Deleted")
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *FTPServer) lookup(name string) (ftpFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir, file := path.Split(name)
+	if path.Clean(dir) != s.dir {
+		return ftpFile{}, false
+	}
+	f, ok := s.files[file]
+	return f, ok
+}
+
+func (s *FTPServer) remove(name string) bool {
+	dir, file := path.Split(name)
+	s.mu.Lock()
+	ok := path.Clean(dir) == s.dir
+	s.mu.Unlock()
+	return ok && s.RemoveFile(file)
+}
+
+// listLines renders directory listing in Unix `ls -l` format with minute
+// precision timestamps or, if `mlsd` is set, in machine readable format.
+func (s *FTPServer) listLines(mlsd bool) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		f := s.files[name]
+		if mlsd {
+			lines = append(lines, fmt.Sprintf("type=file;size=%d;modify=%s; %s",
+				len(f.data), f.mtime.UTC().Format("20060102150405.000"), name))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("-rw-r--r-- 1 ftp ftp %d %s %s",
+			len(f.data), f.mtime.UTC().Format("Jan _2 15:04"), name))
+	}
+	return lines
+}
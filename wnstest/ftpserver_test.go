@@ -0,0 +1,111 @@
+package wnstest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/wnstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFTPServerSnapshot(t *testing.T) {
+	srv := wnstest.NewFTPServer(t)
+	base := time.Now().Add(-time.Hour)
+	srv.AddFile("a.xml", testDoc("a"), base)
+	srv.AddFile("b.xml", testDoc("b"), base.Add(time.Minute))
+
+	c, err := wns.NewFTPPull(srv.URL())
+	require.NoError(t, err)
+
+	docs, last, err := c.Snapshot()
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, "a", docs[0].Timestamp.Created)
+	assert.Equal(t, "b", docs[1].Timestamp.Created)
+	assert.Equal(t, "b.xml", last)
+
+	docs, err = c.Get([]string{"b.xml"})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "b", docs[0].Timestamp.Created)
+
+	require.NoError(t, c.Remove([]string{"a.xml"}))
+	assert.Equal(t, []string{"b.xml"}, srv.Files())
+}
+
+func TestFTPServerStream(t *testing.T) {
+	srv := wnstest.NewFTPServer(t)
+	srv.AddFile("a.xml", testDoc("a"), time.Now().Add(-time.Hour))
+
+	c, err := wns.NewFTPPull(srv.URL())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := c.Stream(ctx, "", 10*time.Millisecond)
+
+	msg := <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "a.xml", msg.Cursor)
+
+	srv.AddFile("b.xml", testDoc("b"), time.Now())
+	msg = <-stream
+	require.NoError(t, msg.Error)
+	assert.Equal(t, "b.xml", msg.Cursor)
+	assert.Equal(t, "b", msg.Data.Timestamp.Created)
+
+	cancel()
+	for range stream {
+	}
+}
+
+func TestFTPServerDrop(t *testing.T) {
+	srv := wnstest.NewFTPServer(t)
+	srv.AddFile("a.xml", testDoc("a"), time.Now())
+
+	c, err := wns.NewFTPPull(srv.URL())
+	require.NoError(t, err)
+	srv.DropNext("RETR", 1)
+	_, err = c.Get([]string{"a.xml"})
+	assert.True(t, errors.Is(err, wns.ErrTransport), err)
+
+	c, err = wns.NewFTPPullWithOptions(srv.URL(), wns.FTPRetry(wns.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	require.NoError(t, err)
+	srv.DropNext("RETR", 1)
+	docs, err := c.Get([]string{"a.xml"})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "a", docs[0].Timestamp.Created)
+	assert.Equal(t, 3, srv.Count("RETR"))
+}
+
+func TestFTPServerLatency(t *testing.T) {
+	srv := wnstest.NewFTPServer(t)
+	srv.AddFile("a.xml", testDoc("a"), time.Now())
+	srv.SetLatency(5 * time.Millisecond)
+	srv.SetTransferDelay(time.Second)
+
+	c, err := wns.NewFTPPullWithOptions(srv.URL(), wns.FTPRetrTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+	_, err = c.Get([]string{"a.xml"})
+	assert.True(t, errors.Is(err, wns.ErrTransport), err)
+
+	srv.SetTransferDelay(0)
+	docs, err := c.Get([]string{"a.xml"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", docs[0].Timestamp.Created)
+}
+
+func TestFTPServerCredentials(t *testing.T) {
+	srv := wnstest.NewFTPServer(t)
+	c, err := wns.NewFTPPull(srv.URL())
+	require.NoError(t, err)
+	srv.SetCredentials("bookie", "secret")
+	_, err = c.List()
+	assert.True(t, errors.Is(err, wns.ErrAuth), err)
+}
@@ -0,0 +1,295 @@
+// Package generator simulates WNS feed of a catalog of lottery games for load
+// and soak testing of WNS consumers. Each draw of a game produces a fixtures
+// document with draw odds ahead of the draw and a results document once
+// balls are drawn. Documents are published to a Sink: a directory, a fake
+// HTTP-pull endpoint or a Data channel.
+package generator
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/internal/sleep"
+	"github.com/advbet/wns/wnstest/builder"
+)
+
+// Game describes a simulated lottery game and its draw schedule. A game is
+// drawn either every Interval or daily at Fixed times.
+type Game struct {
+	Name         string
+	GameType     string // Balls drawn out of the highest ball number, e.g. "20/80"
+	DrawType     string // "Rng" or "Drum", default is "Rng"
+	CategoryID   int
+	Country      string
+	TournamentID int
+
+	BonusBalls     int    // Number of bonus balls drawn
+	BonusBallsDrum string // builder.DrumSame or builder.DrumSeparate
+	BonusRange     int    // Highest bonus ball number of a separate drum
+
+	Interval time.Duration   // Time between draws of interval games
+	Fixed    []time.Duration // Draw times of fixed games as offsets from midnight UTC, sorted
+}
+
+// DefaultCatalog is a selection of typical WNS games.
+var DefaultCatalog = []Game{
+	{
+		Name:         "Quick KENO 20/80",
+		GameType:     "20/80",
+		CategoryID:   1103,
+		Country:      "Malta",
+		TournamentID: 49026,
+		Interval:     5 * time.Minute,
+	},
+	{
+		Name:         "Keno Atlantic 20/70",
+		GameType:     "20/70",
+		DrawType:     "Drum",
+		CategoryID:   1061,
+		Country:      "Canada",
+		TournamentID: 47111,
+		Fixed:        []time.Duration{2*time.Hour + 30*time.Minute, 14*time.Hour + 30*time.Minute},
+	},
+	{
+		Name:           "Lotto 6/49",
+		GameType:       "6/49",
+		DrawType:       "Drum",
+		CategoryID:     1104,
+		Country:        "Germany",
+		TournamentID:   49100,
+		BonusBalls:     1,
+		BonusBallsDrum: builder.DrumSame,
+		Fixed:          []time.Duration{19 * time.Hour},
+	},
+	{
+		Name:           "Euro 5/50",
+		GameType:       "5/50",
+		DrawType:       "Drum",
+		CategoryID:     1105,
+		Country:        "Finland",
+		TournamentID:   49101,
+		BonusBalls:     2,
+		BonusBallsDrum: builder.DrumSeparate,
+		BonusRange:     12,
+		Fixed:          []time.Duration{20 * time.Hour},
+	},
+	{
+		Name:         "Lucky 5/36",
+		GameType:     "5/36",
+		CategoryID:   1103,
+		Country:      "Malta",
+		TournamentID: 49027,
+		Interval:     15 * time.Minute,
+	},
+}
+
+// Replicate returns `n` copies of `games` catalog to simulate higher volumes.
+// Copies are distinct games, their tournament IDs are offset by multiples of
+// 100000 and names are numbered.
+func Replicate(games []Game, n int) []Game {
+	var out []Game
+	for i := 0; i < n; i++ {
+		for _, game := range games {
+			if i > 0 {
+				game.Name = fmt.Sprintf("%s #%d", game.Name, i+1)
+				game.TournamentID += i * 100000
+			}
+			out = append(out, game)
+		}
+	}
+	return out
+}
+
+// Generator publishes simulated documents of a game catalog. Simulated time
+// starts at Start and runs Speed times faster than wall clock.
+type Generator struct {
+	Games []Game // Game catalog, default is DefaultCatalog
+	// Start is the simulated time generator starts at, default is current
+	// time.
+	Start time.Time
+	// End, if set, is the simulated time generator stops at.
+	End time.Time
+	// Speed is the simulation speed factor, e.g. 60 simulates an hour of
+	// draws in a minute. Default is 1, real time. Positive infinity
+	// publishes documents without delays.
+	Speed float64
+	// Lead is how long before the draw its fixtures document is published,
+	// default is 10 minutes. Fixtures of draws less than Lead after Start
+	// are published at Start.
+	Lead time.Duration
+	// Seed of random draws, same settings and seed give same documents.
+	// Draws are numbered from 1 in scheduling order.
+	Seed int64
+}
+
+// event is a single scheduled document.
+type event struct {
+	at      time.Time // Simulated publishing time
+	seq     int       // Scheduling order of events published at the same time
+	drawAt  time.Time
+	game    int
+	draw    *builder.DrawBuilder
+	results bool
+}
+
+type events []event
+
+func (e events) Len() int { return len(e) }
+func (e events) Less(i, j int) bool {
+	if e[i].at.Equal(e[j].at) {
+		return e[i].seq < e[j].seq
+	}
+	return e[i].at.Before(e[j].at)
+}
+func (e events) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e *events) Push(x interface{}) {
+	*e = append(*e, x.(event))
+}
+func (e *events) Pop() interface{} {
+	old := *e
+	x := old[len(old)-1]
+	*e = old[:len(old)-1]
+	return x
+}
+
+// Run publishes documents to `sink` until `ctx` is cancelled or simulated
+// time reaches End. It returns nil if End was reached or publishing error
+// otherwise.
+func (g *Generator) Run(ctx context.Context, sink Sink) error {
+	games := g.Games
+	if games == nil {
+		games = DefaultCatalog
+	}
+	start := g.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	speed := g.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	lead := g.Lead
+	if lead == 0 {
+		lead = 10 * time.Minute
+	}
+	rng := rand.New(rand.NewSource(g.Seed))
+
+	queue := &events{}
+	seq := 0
+	push := func(e event) {
+		seq++
+		e.seq = seq
+		heap.Push(queue, e)
+	}
+	drawID := 0
+	displayIDs := make([]int, len(games))
+	schedule := func(i int, after time.Time) {
+		drawAt := nextDraw(games[i], after)
+		if drawAt.IsZero() {
+			return
+		}
+		drawID++
+		displayIDs[i]++
+		at := drawAt.Add(-lead)
+		if at.Before(start) {
+			at = start
+		}
+		push(event{
+			at:     at,
+			drawAt: drawAt,
+			game:   i,
+			draw:   newDraw(games[i], drawAt, drawID, displayIDs[i], rng.Int63()),
+		})
+	}
+	for i := range games {
+		schedule(i, start)
+	}
+
+	wallStart := time.Now()
+	for queue.Len() > 0 {
+		e := heap.Pop(queue).(event)
+		if !g.End.IsZero() && e.at.After(g.End) {
+			return nil
+		}
+		if !math.IsInf(speed, 1) {
+			wait := time.Duration(float64(e.at.Sub(start)) / speed)
+			if !sleep.Until(ctx, wallStart.Add(wait)) {
+				return ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		game := games[e.game]
+		b := builder.Fixtures()
+		if e.results {
+			b = builder.Results()
+		}
+		b.Created(e.at).Category(game.CategoryID, game.Country).Tournament(game.TournamentID).Draw(e.draw)
+		if err := sink.Publish(ctx, Document{Time: e.at, Data: b.Build(), Raw: b.XML()}); err != nil {
+			return err
+		}
+
+		if !e.results {
+			push(event{at: e.drawAt, drawAt: e.drawAt, game: e.game, draw: e.draw, results: true})
+			schedule(e.game, e.drawAt)
+		}
+	}
+	return nil
+}
+
+// newDraw prepares draw of `game` scheduled at `at`.
+func newDraw(game Game, at time.Time, id, displayID int, seed int64) *builder.DrawBuilder {
+	d := builder.NewDraw(game.GameType).ID(id).DisplayID(displayID).At(at).Seed(seed)
+	if game.Name != "" {
+		d.Name(game.Name)
+	}
+	if game.DrawType != "" {
+		d.Type(game.DrawType)
+	}
+	if game.Interval > 0 {
+		d.TimeType("Interval")
+	} else {
+		d.TimeType("Fixed")
+	}
+	if game.BonusBalls > 0 {
+		d.Bonus(game.BonusBalls, game.BonusBallsDrum)
+		if game.BonusRange > 0 {
+			d.BonusRange(game.BonusRange)
+		}
+	}
+	return d
+}
+
+// nextDraw returns the first draw time of `game` after `t`, or zero time if
+// game has no schedule.
+func nextDraw(game Game, t time.Time) time.Time {
+	if game.Interval > 0 {
+		return t.Truncate(game.Interval).Add(game.Interval)
+	}
+	if len(game.Fixed) == 0 {
+		return time.Time{}
+	}
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	for {
+		for _, offset := range game.Fixed {
+			if at := day.Add(offset); at.After(t) {
+				return at
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+}
+
+// Document is a generated odds document.
+type Document struct {
+	Time time.Time // Simulated publishing time
+	Data wns.BetradarBetData
+	Raw  []byte
+}
@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/wnstest"
+	"github.com/advbet/wns/wnstest/builder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testGames = []Game{
+	{Name: "Keno", GameType: "20/80", TournamentID: 1, Interval: 20 * time.Minute},
+	{Name: "Lotto", GameType: "6/49", TournamentID: 2, BonusBalls: 1, BonusBallsDrum: builder.DrumSame, Fixed: []time.Duration{30 * time.Minute}},
+}
+
+func TestNextDraw(t *testing.T) {
+	day := time.Date(2017, 3, 27, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		msg      string
+		game     Game
+		t        time.Time
+		expected time.Time
+	}{
+		{
+			msg:      "interval",
+			game:     Game{Interval: 5 * time.Minute},
+			t:        day.Add(7 * time.Minute),
+			expected: day.Add(10 * time.Minute),
+		},
+		{
+			msg:      "interval on draw time",
+			game:     Game{Interval: 5 * time.Minute},
+			t:        day.Add(10 * time.Minute),
+			expected: day.Add(15 * time.Minute),
+		},
+		{
+			msg:      "fixed",
+			game:     Game{Fixed: []time.Duration{2 * time.Hour, 14 * time.Hour}},
+			t:        day.Add(2 * time.Hour),
+			expected: day.Add(14 * time.Hour),
+		},
+		{
+			msg:      "fixed next day",
+			game:     Game{Fixed: []time.Duration{2 * time.Hour, 14 * time.Hour}},
+			t:        day.Add(15 * time.Hour),
+			expected: day.Add(26 * time.Hour),
+		},
+		{
+			msg: "no schedule",
+			t:   day,
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, nextDraw(test.game, test.t), test.msg)
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	games := Replicate(testGames, 3)
+	require.Len(t, games, 6)
+	assert.Equal(t, testGames[0], games[0])
+	assert.Equal(t, "Lotto #3", games[5].Name)
+	assert.Equal(t, 200002, games[5].TournamentID)
+}
+
+// generate runs generator to completion and returns published documents.
+func generate(t *testing.T, g *Generator) []Document {
+	var docs []Document
+	err := g.Run(context.Background(), SinkFunc(func(ctx context.Context, doc Document) error {
+		docs = append(docs, doc)
+		return nil
+	}))
+	require.NoError(t, err)
+	return docs
+}
+
+func TestRun(t *testing.T) {
+	start := time.Date(2017, 3, 27, 0, 0, 0, 0, time.UTC)
+	g := &Generator{
+		Games: testGames,
+		Start: start,
+		End:   start.Add(time.Hour),
+		Speed: math.Inf(1),
+		Lead:  25 * time.Minute,
+		Seed:  1,
+	}
+	docs := generate(t, g)
+
+	type expected struct {
+		at    time.Time
+		game  int
+		typ   string
+		draws string
+	}
+	// Keno draws every 20 minutes, lotto draws at 00:30. Fixtures of the
+	// first keno draw are due before start.
+	want := []expected{
+		{start, 1, builder.TypeFixtures, "00:20"},
+		{start.Add(5 * time.Minute), 2, builder.TypeFixtures, "00:30"},
+		{start.Add(15 * time.Minute), 1, builder.TypeFixtures, "00:40"},
+		{start.Add(20 * time.Minute), 1, builder.TypeResults, "00:20"},
+		{start.Add(30 * time.Minute), 2, builder.TypeResults, "00:30"},
+		{start.Add(35 * time.Minute), 1, builder.TypeFixtures, "01:00"},
+		{start.Add(40 * time.Minute), 1, builder.TypeResults, "00:40"},
+		{start.Add(55 * time.Minute), 1, builder.TypeFixtures, "01:20"},
+		{start.Add(60 * time.Minute), 1, builder.TypeResults, "01:00"},
+	}
+	require.Len(t, docs, len(want))
+	for i, w := range want {
+		doc := docs[i]
+		assert.Equal(t, w.at, doc.Time, i)
+		assert.Equal(t, w.typ, doc.Data.Type, i)
+		tournament := doc.Data.Sports[0].Category.Tournament
+		assert.Equal(t, w.game, tournament.ID, i)
+		require.Len(t, tournament.Draws, 1, i)
+		draw := tournament.Draws[0]
+		assert.Equal(t, "2017-03-27 "+w.draws+":00", draw.DrawDate, i)
+
+		var parsed wns.BetradarBetData
+		require.NoError(t, xml.Unmarshal(doc.Raw, &parsed), i)
+		assert.Equal(t, doc.Data, parsed, i)
+
+		if w.typ == builder.TypeResults {
+			assert.Len(t, draw.Result.ScoreInfo, map[int]int{1: 20, 2: 7}[w.game], i)
+		} else {
+			assert.Empty(t, draw.Result.ScoreInfo, i)
+			assert.NotEmpty(t, tournament.Bets, i)
+		}
+	}
+
+	// Fixtures and results of a draw share draw ID.
+	assert.Equal(t, docs[0].Data.Sports[0].Category.Tournament.Draws[0].ID, docs[3].Data.Sports[0].Category.Tournament.Draws[0].ID)
+
+	// Same seed gives same documents.
+	assert.Equal(t, docs, generate(t, g))
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	g := &Generator{Games: testGames}
+	err := g.Run(ctx, SinkFunc(func(ctx context.Context, doc Document) error {
+		return nil
+	}))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestSinks(t *testing.T) {
+	start := time.Date(2017, 3, 27, 0, 0, 0, 0, time.UTC)
+	g := &Generator{
+		Games: testGames,
+		Start: start,
+		End:   start.Add(time.Hour),
+		Speed: math.Inf(1),
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, g.Run(context.Background(), DirSink{Dir: dir}))
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 8)
+	raw, err := ioutil.ReadFile(files[0])
+	require.NoError(t, err)
+	var doc wns.BetradarBetData
+	require.NoError(t, xml.Unmarshal(raw, &doc))
+	assert.Equal(t, builder.TypeFixtures, doc.Type)
+
+	srv := wnstest.NewServer("bookie", "secret")
+	defer srv.Close()
+	require.NoError(t, g.Run(context.Background(), ServerSink{Server: srv}))
+	assert.Equal(t, 8, srv.Len())
+
+	ch := make(chan wns.Data)
+	go func() {
+		defer close(ch)
+		assert.NoError(t, g.Run(context.Background(), ChanSink(ch)))
+	}()
+	var msgs []wns.Data
+	for msg := range ch {
+		msgs = append(msgs, msg)
+	}
+	require.Len(t, msgs, 8)
+	assert.Equal(t, start.Add(10*time.Minute), msgs[0].Received)
+	assert.Equal(t, filepath.Base(files[0]), msgs[0].Cursor)
+	assert.Equal(t, builder.TypeFixtures, msgs[0].Data.Type)
+}
@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/internal/atomicfile"
+	"github.com/advbet/wns/wnstest"
+)
+
+// Sink receives generated documents.
+type Sink interface {
+	Publish(ctx context.Context, doc Document) error
+}
+
+// SinkFunc is an adapter to use ordinary functions as a Sink.
+type SinkFunc func(ctx context.Context, doc Document) error
+
+// Publish implements Sink interface.
+func (f SinkFunc) Publish(ctx context.Context, doc Document) error {
+	return f(ctx, doc)
+}
+
+// timeLayout is used for naming document files, so that lexical order of
+// file names is the publishing order. It matches wns.FSRecorder naming, so
+// generated directories can also be replayed with wns.ReplaySource.
+const timeLayout = "20060102T150405.000000000Z"
+
+// filename returns a unique name of document file.
+func filename(doc Document) string {
+	typ := doc.Data.Type
+	id := 0
+	if len(doc.Data.Sports) > 0 {
+		if draws := doc.Data.Sports[0].Category.Tournament.Draws; len(draws) > 0 {
+			id = draws[0].ID
+		}
+	}
+	return fmt.Sprintf("%s-%d-%s.xml", doc.Time.UTC().Format(timeLayout), id, typ)
+}
+
+// DirSink writes documents to files in a directory, e.g. one watched by
+// wns.DirSource. Files are written atomically, they appear under the final
+// name only when complete.
+type DirSink struct {
+	Dir string
+}
+
+// Publish implements Sink interface.
+func (s DirSink) Publish(ctx context.Context, doc Document) error {
+	name := filepath.Join(s.Dir, filename(doc))
+	return atomicfile.WriteFile(name, 0644, func(w io.Writer) error {
+		_, err := w.Write(doc.Raw)
+		return err
+	})
+}
+
+// ServerSink enqueues documents to a fake WNS HTTP-pull endpoint.
+type ServerSink struct {
+	Server *wnstest.Server
+}
+
+// Publish implements Sink interface.
+func (s ServerSink) Publish(ctx context.Context, doc Document) error {
+	s.Server.Enqueue(doc.Raw)
+	return nil
+}
+
+// ChanSink delivers documents as stream values, like wns streams do. Data
+// values are received at the simulated publishing time.
+type ChanSink chan<- wns.Data
+
+// Publish implements Sink interface. It blocks until the document is
+// received or `ctx` is cancelled.
+func (ch ChanSink) Publish(ctx context.Context, doc Document) error {
+	hash := sha256.Sum256(doc.Raw)
+	name := filename(doc)
+	d := wns.Data{
+		Data:     doc.Data,
+		Filename: name,
+		Raw:      doc.Raw,
+		Size:     len(doc.Raw),
+		SHA256:   hex.EncodeToString(hash[:]),
+		Received: doc.Time,
+		Source:   "generator",
+		Charset:  "UTF-8",
+		Cursor:   name,
+	}
+	select {
+	case ch <- d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,209 @@
+// Package wnstest provides fakes of Betradar WNS delivery endpoints and
+// record/replay HTTP transports for testing WNS clients offline. It does not
+// depend on wns package, so it is usable by wns package tests too.
+package wnstest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Error message bodies returned by WNS HTTP-pull endpoint.
+const (
+	NoFilesBody     = `<error-message>There are no files ready for transfer at the moment.</error-message>`
+	TooFrequentBody = `<error-message>Too frequent download. (From IP: %s, %d seconds ago)</error-message>`
+)
+
+// Placeholder error bodies for rejected requests. WNS responses to invalid
+// bookmaker name, key or feed name are not documented, these bodies are made
+// up. They only make the fake server reject such requests and must not be
+// relied on to test how clients classify real WNS errors.
+const (
+	InvalidBookmakerBody = `<error-message>PLACEHOLDER: invalid bookmaker name.</error-message>`
+	InvalidKeyBody       = `<error-message>PLACEHOLDER: invalid key.</error-message>`
+	UnknownFeedBody      = `<error-message>PLACEHOLDER: unknown xmlFeedName.</error-message>`
+)
+
+// DefaultRateLimit is the minimum time between two requests enforced by WNS.
+const DefaultRateLimit = 10 * time.Second
+
+// Server is a fake of WNS HTTP-pull endpoint getXmlFeed.php. It serves odds
+// documents from a scriptable queue, validates bookmakerName and key query
+// parameters and enforces the rate limit.
+//
+// Server is an http.Handler, it can be served by embedded httptest.Server,
+// see NewServer, or by any other HTTP server.
+type Server struct {
+	*httptest.Server // Started by NewServer, nil otherwise
+
+	Bookmaker string // Expected bookmakerName parameter
+	Key       string // Expected key parameter
+	// RateLimit is the minimum time between two accepted requests, more
+	// frequent requests are rejected with "Too frequent download" error.
+	// Zero disables rate limiting.
+	RateLimit time.Duration
+
+	mu       sync.Mutex
+	queue    [][]byte
+	faults   []Fault
+	last     time.Time
+	requests int
+}
+
+// Fault is an injected failure, it replaces the response of a single request.
+type Fault struct {
+	Delay       time.Duration // Delay before responding
+	Status      int           // HTTP status code, default is 200
+	ContentType string        // Response content type, default is text/xml
+	Body        string        // Response body
+	Truncate    int           // If non zero, response body is cut after Truncate bytes of the head of the queue
+	Drop        bool          // Close connection without response, note that HTTP transport retries such requests on reused connections
+}
+
+// NewServer starts a fake WNS endpoint accepting `bookmaker` and `key`
+// credentials, feed URL is the URL field of the server. Rate limit is set to
+// DefaultRateLimit. Server must be closed with Close method.
+func NewServer(bookmaker, key string) *Server {
+	s := &Server{
+		Bookmaker: bookmaker,
+		Key:       key,
+		RateLimit: DefaultRateLimit,
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Enqueue appends odds documents to the queue.
+func (s *Server) Enqueue(docs ...[]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, docs...)
+}
+
+// Len returns the number of queued odds documents.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Requests returns the number of requests received so far, including rejected
+// ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Inject schedules faults for the following requests, one fault per request.
+// Faulty requests bypass credentials check and rate limit, they do not modify
+// the queue, except for Truncate faults that consume the head of the queue.
+func (s *Server) Inject(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// ServeHTTP implements http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	if len(s.faults) > 0 {
+		f := s.faults[0]
+		s.faults = s.faults[1:]
+		var body string
+		if f.Truncate > 0 && len(s.queue) > 0 {
+			body = string(s.queue[0])
+			if f.Truncate < len(body) {
+				body = body[:f.Truncate]
+			}
+			s.queue = s.queue[1:]
+		}
+		s.mu.Unlock()
+		s.fault(w, f, body)
+		return
+	}
+	defer s.mu.Unlock()
+
+	q := r.URL.Query()
+	switch {
+	case q.Get("bookmakerName") != s.Bookmaker:
+		respond(w, InvalidBookmakerBody)
+		return
+	case q.Get("key") != s.Key:
+		respond(w, InvalidKeyBody)
+		return
+	case q.Get("xmlFeedName") != "FileGet":
+		respond(w, UnknownFeedBody)
+		return
+	}
+
+	now := time.Now()
+	if ago := now.Sub(s.last); s.RateLimit > 0 && ago < s.RateLimit {
+		respond(w, fmt.Sprintf(TooFrequentBody, remoteIP(r), int(ago/time.Second)))
+		return
+	}
+	s.last = now
+
+	if q.Get("deleteFullQueue") == "yes" {
+		s.queue = nil
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if len(s.queue) == 0 {
+		respond(w, NoFilesBody)
+		return
+	}
+	doc := s.queue[0]
+	if q.Get("deleteAfterTransfer") == "yes" {
+		s.queue = s.queue[1:]
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write(doc)
+}
+
+// fault writes response of an injected fault. If body is not empty, it is
+// truncated document.
+func (s *Server) fault(w http.ResponseWriter, f Fault, body string) {
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+	if f.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if body == "" {
+		body = f.Body
+	}
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "text/xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if f.Status != 0 {
+		w.WriteHeader(f.Status)
+	}
+	_, _ = w.Write([]byte(body))
+}
+
+func respond(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte(body))
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,127 @@
+package wnstest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/advbet/wns"
+	"github.com/advbet/wns/wnstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDoc(created string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?><BetradarBetData DocumentType="Results"><Timestamp CreatedTime="` + created + `" TimeZone="UTC"/></BetradarBetData>`)
+}
+
+func TestServerQueue(t *testing.T) {
+	srv := wnstest.NewServer("bookie", "secret")
+	defer srv.Close()
+	srv.RateLimit = 0
+	srv.Enqueue(testDoc("a"), testDoc("b"), testDoc("c"))
+
+	c := wns.HTTPPullClient{Username: "bookie", Key: "secret", URL: srv.URL}
+	ctx := context.Background()
+
+	doc, err := c.Get(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, "a", doc.Timestamp.Created)
+	doc, err = c.Get(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, "a", doc.Timestamp.Created)
+	doc, err = c.Get(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, "b", doc.Timestamp.Created)
+	assert.Equal(t, 1, srv.Len())
+
+	require.NoError(t, c.Clear(ctx))
+	assert.Equal(t, 0, srv.Len())
+	_, err = c.Get(ctx, true)
+	assert.True(t, errors.Is(err, wns.ErrNoData), err)
+	assert.Equal(t, 5, srv.Requests())
+}
+
+func TestServerRejects(t *testing.T) {
+	srv := wnstest.NewServer("bookie", "secret")
+	defer srv.Close()
+	srv.RateLimit = 0
+	srv.Enqueue(testDoc("a"))
+
+	tests := []struct {
+		msg    string
+		client wns.HTTPPullClient
+	}{
+		{
+			msg:    "invalid bookmaker",
+			client: wns.HTTPPullClient{Username: "other", Key: "secret", URL: srv.URL},
+		},
+		{
+			msg:    "invalid key",
+			client: wns.HTTPPullClient{Username: "bookie", Key: "wrong", URL: srv.URL},
+		},
+	}
+	for _, test := range tests {
+		// Placeholder bodies only prove the request was rejected, not
+		// how a client classifies real WNS errors.
+		_, err := test.client.Get(context.Background(), true)
+		require.Error(t, err, test.msg)
+		assert.Contains(t, err.Error(), "PLACEHOLDER", test.msg)
+	}
+	assert.Equal(t, 1, srv.Len(), "rejected requests must not consume the queue")
+}
+
+func TestServerRateLimit(t *testing.T) {
+	srv := wnstest.NewServer("bookie", "secret")
+	defer srv.Close()
+	srv.RateLimit = time.Hour
+
+	c := wns.HTTPPullClient{Username: "bookie", Key: "secret", URL: srv.URL}
+	_, err := c.Get(context.Background(), true)
+	assert.True(t, errors.Is(err, wns.ErrNoData), err)
+	_, err = c.Get(context.Background(), true)
+	require.True(t, errors.Is(err, wns.ErrRateLimited), err)
+
+	var apiErr *wns.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Contains(t, apiErr.Err, "0 seconds ago")
+}
+
+func TestServerFaults(t *testing.T) {
+	srv := wnstest.NewServer("bookie", "secret")
+	defer srv.Close()
+	srv.RateLimit = 0
+	srv.Enqueue(testDoc("a"), testDoc("b"))
+	srv.Inject(
+		wnstest.Fault{Status: http.StatusBadGateway, Body: "Bad Gateway", ContentType: "text/plain"},
+		wnstest.Fault{Body: "<html>Maintenance</html>", ContentType: "text/html"},
+		wnstest.Fault{Truncate: 40},
+		wnstest.Fault{Drop: true},
+	)
+
+	// Dropped requests on reused connections are retried by HTTP
+	// transport, keep-alives are disabled to observe the drop.
+	c := wns.HTTPPullClient{
+		Username:   "bookie",
+		Key:        "secret",
+		URL:        srv.URL,
+		HTTPClient: http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+	}
+	ctx := context.Background()
+	_, err := c.Get(ctx, true)
+	assert.True(t, errors.Is(err, &wns.APIError{Type: wns.ErrTypeServer}), err)
+	_, err = c.Get(ctx, true)
+	assert.True(t, errors.Is(err, &wns.APIError{Type: wns.ErrTypeContentType}), err)
+	_, err = c.Get(ctx, true)
+	assert.True(t, errors.Is(err, &wns.APIError{Type: wns.ErrTypeTruncated}), err)
+	_, err = c.Get(ctx, true)
+	assert.True(t, errors.Is(err, wns.ErrTransport), err)
+
+	// Truncated document is consumed from the queue.
+	doc, err := c.Get(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, "b", doc.Timestamp.Created)
+}